@@ -0,0 +1,186 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package chain
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/crypto/bls"
+	"github.com/ava-labs/avalanchego/vms/platformvm/warp"
+)
+
+// SignerSet maps a ChunkCertificate.Signers bit index to the signer's
+// BLS public key for the epoch the certificate was signed in, so
+// BatchVerifier can aggregate exactly the recorded signers without the
+// caller re-scanning the full validator set on every certificate.
+type SignerSet interface {
+	PublicKey(index int) (*bls.PublicKey, bool)
+}
+
+// batchItem is one (pubkey, message, signature) triple queued for
+// aggregate verification. msg is the already-wrapped warp message
+// bytes, cached here so Digest() is invoked exactly once per item
+// regardless of whether the batch later falls back to per-item
+// bls.Verify calls.
+type batchItem struct {
+	pk  *bls.PublicKey
+	msg []byte
+	sig *bls.Signature
+
+	onResult func(bool)
+}
+
+// BatchVerifier accumulates ChunkSignature/ChunkCertificate signature
+// checks and resolves them with one bls.AggregateSignatures +
+// bls.AggregateVerify pairing computation per batch instead of a
+// bls.Verify per item -- the cost that dominates CPU on a busy shard
+// once chunk volume (and so ChunkSignature volume) scales up.
+//
+// Intended to be held as a single long-lived instance by the
+// chunk-signature gossip handler (e.g. one per *vm.TxBlockManager) and
+// fed every signature/certificate it sees; items flush once BatchSize
+// is reached or FlushInterval elapses since the first item in the
+// batch, whichever comes first, so a slow trickle of signatures still
+// resolves promptly.
+type BatchVerifier struct {
+	networkID uint32
+	chainID   ids.ID
+
+	batchSize     int
+	flushInterval time.Duration
+
+	mu    sync.Mutex
+	items []*batchItem
+	timer *time.Timer
+}
+
+// NewBatchVerifier returns a BatchVerifier that wraps digests the same
+// way Chunk/ChunkSignature/ChunkCertificate.VerifySignature do (a warp
+// UnsignedMessage over networkID/chainID), batching up to batchSize
+// items or flushInterval, whichever comes first.
+func NewBatchVerifier(networkID uint32, chainID ids.ID, batchSize int, flushInterval time.Duration) *BatchVerifier {
+	return &BatchVerifier{
+		networkID:     networkID,
+		chainID:       chainID,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+	}
+}
+
+// AddChunkSignature queues s for batch verification. onResult is called
+// exactly once, from whichever goroutine ends up flushing this item's
+// batch, reporting whether s.Signature verifies over s.Digest().
+func (b *BatchVerifier) AddChunkSignature(s *ChunkSignature, onResult func(bool)) error {
+	digest, err := s.Digest()
+	if err != nil {
+		return err
+	}
+	b.add(s.Signer, b.wrap(digest), s.Signature, onResult)
+	return nil
+}
+
+// AddChunkCertificate queues cert for batch verification, aggregating
+// the public keys of every validator cert.Signers records via signers.
+// onResult is called exactly once reporting whether cert.Signature
+// verifies as a valid aggregate over cert.Digest() from that signer set.
+func (b *BatchVerifier) AddChunkCertificate(cert *ChunkCertificate, signers SignerSet, onResult func(bool)) error {
+	pks := make([]*bls.PublicKey, 0, cert.Signers.Len())
+	for i := 0; i < cert.Signers.Len(); i++ {
+		if !cert.Signers.Contains(i) {
+			continue
+		}
+		pk, ok := signers.PublicKey(i)
+		if !ok {
+			onResult(false)
+			return nil
+		}
+		pks = append(pks, pk)
+	}
+	aggPK, err := bls.AggregatePublicKeys(pks)
+	if err != nil {
+		return err
+	}
+	digest, err := cert.Digest()
+	if err != nil {
+		return err
+	}
+	b.add(aggPK, b.wrap(digest), cert.Signature, onResult)
+	return nil
+}
+
+func (b *BatchVerifier) wrap(digest []byte) []byte {
+	msg := &warp.UnsignedMessage{
+		NetworkID:     b.networkID,
+		SourceChainID: b.chainID,
+		Payload:       digest,
+	}
+	return msg.Bytes()
+}
+
+func (b *BatchVerifier) add(pk *bls.PublicKey, msg []byte, sig *bls.Signature, onResult func(bool)) {
+	b.mu.Lock()
+	b.items = append(b.items, &batchItem{pk: pk, msg: msg, sig: sig, onResult: onResult})
+	flush := len(b.items) >= b.batchSize
+	if len(b.items) == 1 && !flush {
+		b.timer = time.AfterFunc(b.flushInterval, b.Flush)
+	}
+	var items []*batchItem
+	if flush {
+		items, b.items = b.items, nil
+		if b.timer != nil {
+			b.timer.Stop()
+		}
+	}
+	b.mu.Unlock()
+
+	if flush {
+		b.verify(items)
+	}
+}
+
+// Flush verifies whatever is currently queued even if it hasn't reached
+// batchSize yet. Called by the flush-interval timer; safe to call
+// directly too (e.g. on shutdown, to resolve stragglers immediately).
+func (b *BatchVerifier) Flush() {
+	b.mu.Lock()
+	items := b.items
+	b.items = nil
+	b.mu.Unlock()
+
+	if len(items) > 0 {
+		b.verify(items)
+	}
+}
+
+// verify checks items as a single aggregate-signature batch; if
+// aggregation or the batch pairing check fails, it falls back to
+// verifying every item individually with bls.Verify so one bad
+// signature can't mask the rest of the batch being valid.
+func (b *BatchVerifier) verify(items []*batchItem) {
+	sigs := make([]*bls.Signature, len(items))
+	for i, it := range items {
+		sigs[i] = it.sig
+	}
+
+	if aggSig, err := bls.AggregateSignatures(sigs); err == nil {
+		pks := make([]*bls.PublicKey, len(items))
+		msgs := make([][]byte, len(items))
+		for i, it := range items {
+			pks[i] = it.pk
+			msgs[i] = it.msg
+		}
+		if bls.AggregateVerify(pks, msgs, aggSig) {
+			for _, it := range items {
+				it.onResult(true)
+			}
+			return
+		}
+	}
+
+	for _, it := range items {
+		it.onResult(bls.Verify(it.pk, it.sig, it.msg))
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package chain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/crypto/bls"
+	"github.com/stretchr/testify/require"
+)
+
+func newSignedItem(t *testing.T, msg []byte) (*bls.PublicKey, *bls.Signature) {
+	t.Helper()
+	sk, err := bls.NewSecretKey()
+	require.NoError(t, err)
+	return bls.PublicFromSecretKey(sk), bls.Sign(sk, msg)
+}
+
+// TestBatchVerifierAllValid checks that a batch where every signature is
+// valid resolves every item true via the aggregate pairing check, without
+// needing the per-item fallback.
+func TestBatchVerifierAllValid(t *testing.T) {
+	b := NewBatchVerifier(1, ids.Empty, 10, time.Hour)
+
+	results := make([]bool, 3)
+	for i := range results {
+		pk, sig := newSignedItem(t, []byte{byte(i)})
+		i := i
+		b.add(pk, b.wrap([]byte{byte(i)}), sig, func(ok bool) { results[i] = ok })
+	}
+	b.Flush()
+
+	for i, ok := range results {
+		require.Truef(t, ok, "item %d", i)
+	}
+}
+
+// TestBatchVerifierFallsBackOnBadSignature checks that one corrupted
+// signature in a batch doesn't mask the rest: verify must fall back to
+// per-item bls.Verify so the good signatures still resolve true and only
+// the bad one resolves false.
+func TestBatchVerifierFallsBackOnBadSignature(t *testing.T) {
+	b := NewBatchVerifier(1, ids.Empty, 10, time.Hour)
+
+	results := make([]bool, 3)
+	for i := range results {
+		msg := []byte{byte(i)}
+		pk, sig := newSignedItem(t, msg)
+		if i == 1 {
+			// Sign the wrong message so this item's signature is invalid,
+			// poisoning the aggregate pairing check for the whole batch.
+			_, sig = newSignedItem(t, []byte("wrong"))
+		}
+		i := i
+		b.add(pk, b.wrap(msg), sig, func(ok bool) { results[i] = ok })
+	}
+	b.Flush()
+
+	require.True(t, results[0])
+	require.False(t, results[1])
+	require.True(t, results[2])
+}
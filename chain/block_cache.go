@@ -0,0 +1,64 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package chain
+
+import (
+	"sync"
+
+	"github.com/ava-labs/avalanchego/cache"
+	"github.com/ava-labs/avalanchego/ids"
+)
+
+// DefaultBlockCacheSize bounds how many state keys [blockCache] retains
+// across blocks, on the same order of magnitude as numTxs: most keys one
+// block touches are touched again by the next few.
+const DefaultBlockCacheSize = 100_000
+
+// blockCache is a [DefaultProcessor]'s carry-over view of recently read
+// state, shared from one block's Run to the next instead of rebuilt from
+// scratch every time -- unlike [DefaultProcessor.cache], which is a
+// per-block overlay discarded whether the block succeeds or not.
+//
+// A VM owns the single [blockCache] instance it hands to successive
+// [DefaultProcessor]s (via [VM.GetBlockCache]) and is responsible for
+// calling [DefaultProcessor.Reset] with the new parent's root whenever
+// that parent changes, so a reorg can't leave reads from an abandoned
+// fork silently feeding the next block's execution.
+type blockCache struct {
+	mu   sync.Mutex
+	root ids.ID
+	lru  *cache.LRU[string, *fetchData]
+}
+
+// newBlockCache creates a [blockCache] holding up to size entries (falling
+// back to [DefaultBlockCacheSize] if size <= 0).
+func newBlockCache(size int) *blockCache {
+	if size <= 0 {
+		size = DefaultBlockCacheSize
+	}
+	return &blockCache{lru: &cache.LRU[string, *fetchData]{Size: size}}
+}
+
+// Reset drops every carried-over entry if root no longer matches the
+// parent root the cache was last built against. Called with the same
+// root twice in a row (the common case: the next block has the same
+// parent as the last one processed) is a no-op.
+func (bc *blockCache) Reset(root ids.ID) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if bc.root == root {
+		return
+	}
+	bc.root = root
+	bc.lru = &cache.LRU[string, *fetchData]{Size: bc.lru.Size}
+}
+
+func (bc *blockCache) get(k string) (*fetchData, bool) {
+	return bc.lru.Get(k)
+}
+
+func (bc *blockCache) put(k string, v *fetchData) {
+	bc.lru.Put(k, v)
+}
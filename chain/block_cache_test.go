@@ -0,0 +1,48 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package chain
+
+import (
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBlockCacheGetPut checks the basic get/put contract, including the
+// size<=0 fallback to DefaultBlockCacheSize.
+func TestBlockCacheGetPut(t *testing.T) {
+	require := require.New(t)
+	bc := newBlockCache(0)
+	require.Equal(DefaultBlockCacheSize, bc.lru.Size)
+
+	_, ok := bc.get("k")
+	require.False(ok)
+
+	bc.put("k", &fetchData{v: []byte("v"), exists: true})
+	v, ok := bc.get("k")
+	require.True(ok)
+	require.Equal([]byte("v"), v.v)
+}
+
+// TestBlockCacheResetDropsOnRootChange checks that Reset is a no-op when
+// called with the same root twice (the common case, same parent as last
+// time) but clears every entry once the root actually changes -- a reorg
+// must not leak reads from an abandoned fork into the next block.
+func TestBlockCacheResetDropsOnRootChange(t *testing.T) {
+	require := require.New(t)
+	bc := newBlockCache(10)
+	root := ids.GenerateTestID()
+
+	bc.Reset(root)
+	bc.put("k", &fetchData{exists: true})
+
+	bc.Reset(root)
+	_, ok := bc.get("k")
+	require.True(ok, "same root twice must not drop entries")
+
+	bc.Reset(ids.GenerateTestID())
+	_, ok = bc.get("k")
+	require.False(ok, "a new root must drop carried-over entries")
+}
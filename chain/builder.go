@@ -12,14 +12,18 @@ import (
 	"time"
 
 	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/ids"
 	smblock "github.com/ava-labs/avalanchego/snow/engine/snowman/block"
+	"github.com/ava-labs/avalanchego/snow/validators"
 	"github.com/ava-labs/avalanchego/utils/logging"
 	"github.com/ava-labs/avalanchego/utils/math"
 	"github.com/ava-labs/avalanchego/utils/set"
 	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 
+	"github.com/ava-labs/hypersdk/executor"
 	"github.com/ava-labs/hypersdk/keys"
+	"github.com/ava-labs/hypersdk/state"
 	"github.com/ava-labs/hypersdk/tstate"
 )
 
@@ -32,7 +36,57 @@ const (
 	stopBuildingThreshold   = 2_048 // units
 )
 
-var errBlockFull = errors.New("block full")
+var (
+	errBlockFull = errors.New("block full")
+
+	// ErrBuildCanceled is returned when [vm.BuildCancelCh] fires while a
+	// build is in progress, e.g. because a new preferred parent was set or a
+	// higher-priority block arrived. The caller should retry BuildBlock
+	// against the (possibly new) parent, passing back the returned
+	// [BuildCheckpoint] so the retry doesn't re-prefetch state this attempt
+	// already fetched.
+	ErrBuildCanceled = errors.New("build canceled")
+
+	// errPolicyRejected is passed to [BuilderPolicy.OnRejected] when a tx is
+	// dropped or returned to the mempool because [BuilderPolicy.ShouldConsider]
+	// declined it.
+	errPolicyRejected = errors.New("rejected by builder policy")
+)
+
+// BuildCheckpoint carries prefetch progress from a canceled BuildBlock
+// attempt into the next one against the same parent, so consensus engines
+// that re-call BuildBlock frequently as their view of the tip changes don't
+// pay for the same state reads on every attempt. It only carries a pure
+// read cache: usedKeys (which txs actually ended up in the block, used to
+// classify cold/warm reads for fees) is not in here and must start empty
+// on every attempt, since a canceled attempt's included txs are returned
+// to the mempool rather than reused, and seeding it from a discarded
+// attempt would let the new block's fee accounting diverge from what
+// Verify recomputes fresh per finalized block.
+type BuildCheckpoint struct {
+	alreadyFetched map[string]*fetchData
+}
+
+// NewBuildCheckpoint returns an empty [BuildCheckpoint], suitable for the
+// first build attempt against a given parent.
+func NewBuildCheckpoint() *BuildCheckpoint {
+	return &BuildCheckpoint{
+		alreadyFetched: map[string]*fetchData{},
+	}
+}
+
+// conflicts reports whether any of [declared] is present in [included],
+// i.e. whether a tx declaring [declared] as its Conflicts would be
+// incompatible with a block that has already included everything in
+// [included].
+func conflicts(declared []ids.ID, included set.Set[ids.ID]) bool {
+	for _, id := range declared {
+		if included.Contains(id) {
+			return true
+		}
+	}
+	return false
+}
 
 func HandlePreExecute(log logging.Logger, err error) bool {
 	switch {
@@ -57,16 +111,37 @@ func HandlePreExecute(log logging.Logger, err error) bool {
 	}
 }
 
+// BuildBlock builds a block on top of [parent]. [checkpoint] may be the
+// [BuildCheckpoint] returned by a prior, canceled attempt to build on the
+// same [parent]; pass nil to start fresh. If [vm.BuildCancelCh] fires before
+// the block is finished, BuildBlock stops quickly, restores every tx it
+// drained from the mempool, and returns [ErrBuildCanceled] along with a
+// [BuildCheckpoint] the next attempt should pass back in.
 func BuildBlock(
 	ctx context.Context,
 	vm VM,
 	parent *StatelessBlock,
 	blockContext *smblock.Context,
-) (*StatelessBlock, error) {
+	checkpoint *BuildCheckpoint,
+) (*StatelessBlock, *BuildCheckpoint, error) {
 	ctx, span := vm.Tracer().Start(ctx, "chain.BuildBlock")
 	defer span.End()
 	log := vm.Logger()
 
+	cancelCh := vm.BuildCancelCh()
+	canceled := func() bool {
+		select {
+		case <-cancelCh:
+			return true
+		default:
+			return false
+		}
+	}
+
+	if checkpoint == nil {
+		checkpoint = NewBuildCheckpoint()
+	}
+
 	// We don't need to fetch the [VerifyContext] because
 	// we will always have a block to build on.
 
@@ -75,7 +150,7 @@ func BuildBlock(
 	r := vm.Rules(nextTime)
 	if nextTime < parent.Tmstmp+r.GetMinBlockGap() {
 		log.Warn("block building failed", zap.Error(ErrTimestampTooEarly))
-		return nil, ErrTimestampTooEarly
+		return nil, checkpoint, ErrTimestampTooEarly
 	}
 	b := NewBlock(vm, parent, nextTime)
 
@@ -88,19 +163,19 @@ func BuildBlock(
 	parentView, err := parent.View(ctx, true)
 	if err != nil {
 		log.Warn("block building failed: couldn't get parent db", zap.Error(err))
-		return nil, err
+		return nil, checkpoint, err
 	}
 
 	// Compute next unit prices to use
 	feeKey := FeeKey(vm.StateManager().FeeKey())
 	feeRaw, err := parentView.GetValue(ctx, feeKey)
 	if err != nil {
-		return nil, err
+		return nil, checkpoint, err
 	}
 	parentFeeManager := NewFeeManager(feeRaw)
 	feeManager, err := parentFeeManager.ComputeNext(parent.Tmstmp, nextTime, r)
 	if err != nil {
-		return nil, err
+		return nil, checkpoint, err
 	}
 	maxUnits := r.GetMaxBlockUnits()
 	targetUnits := r.GetWindowTargetUnits()
@@ -108,8 +183,10 @@ func BuildBlock(
 	ts := tstate.New(changesEstimate, vm.GetPrefetchPathBatch())
 	state, err := vm.State()
 	if err != nil {
-		return nil, err
+		return nil, checkpoint, err
 	}
+	ccc := NewCCC(r.GetCCCPerTxCaps(), r.GetCCCBlockCaps(), streamBatch)
+	defer ccc.Close()
 	var (
 		oldestAllowed = nextTime - r.GetValidityWindow()
 
@@ -121,6 +198,7 @@ func BuildBlock(
 
 		vdrState = vm.ValidatorState()
 		sm       = vm.StateManager()
+		policy   = vm.Policy()
 
 		start = time.Now()
 
@@ -128,8 +206,10 @@ func BuildBlock(
 		restorable = []*Transaction{}
 
 		// alreadyFetched contains keys already fetched from state that can be
-		// used during prefetching.
-		alreadyFetched = map[string]*fetchData{}
+		// used during prefetching. Seeded from [checkpoint] so a retry
+		// against the same parent doesn't refetch what a canceled attempt
+		// already has.
+		alreadyFetched = checkpoint.alreadyFetched
 
 		// prepareStreamLock ensures we don't overwrite stream prefetching spawned
 		// asynchronously.
@@ -139,8 +219,9 @@ func BuildBlock(
 	// Batch fetch items from mempool to unblock incoming RPC/Gossip traffic
 	mempool.StartStreaming(ctx)
 	b.Txs = []*Transaction{}
-	usedKeys := set.NewSet[string](0) // prefetch map for transactions in block
-	for time.Since(start) < vm.GetTargetBuildDuration() {
+	usedKeys := set.NewSet[string](0)    // txs actually included this attempt, for cold/warm fee classification
+	conflictSet := set.NewSet[ids.ID](0) // IDs of included txs plus everything they declared a conflict with
+	for !canceled() && time.Since(start) < vm.GetTargetBuildDuration() {
 		prepareStreamLock.Lock()
 		txs := mempool.Stream(ctx, streamBatch)
 		prepareStreamLock.Unlock()
@@ -156,6 +237,13 @@ func BuildBlock(
 			readyTxs  = make(chan *txData, len(txs))
 			stopIndex = -1
 			execErr   error
+
+			// prefetchRejected collects txs [policy] declined to even
+			// prefetch. Only this goroutine writes to it; BuildBlock only
+			// reads it after ranging over (the now-closed) readyTxs, which
+			// happens-after this goroutine's final write, so no lock is
+			// needed.
+			prefetchRejected []*Transaction
 		)
 		go func() {
 			ctx, prefetchKeysSpan := vm.Tracer().Start(ctx, "chain.BuildBlock.PrefetchKeys")
@@ -167,6 +255,10 @@ func BuildBlock(
 					stopIndex = i
 					return
 				}
+				if canceled() {
+					stopIndex = i
+					return
+				}
 
 				// Once we get part way through a prefetching job, we start
 				// to prepare for the next stream.
@@ -178,6 +270,28 @@ func BuildBlock(
 					}()
 				}
 
+				// Give the policy a chance to reject [tx] before we pay for
+				// prefetching its state. [state] is nil here -- we haven't
+				// read anything yet -- so this only helps policies that
+				// decide from the tx itself (e.g. an author allow-list or a
+				// reserved-unit budget); BuildBlock asks again with the
+				// populated view immediately before PreExecute.
+				if policy != nil {
+					include, shouldRestore, err := policy.ShouldConsider(ctx, tx, nil)
+					if err != nil {
+						execErr = err
+						stopIndex = i
+						return
+					}
+					if !include {
+						policy.OnRejected(ctx, tx, errPolicyRejected)
+						if shouldRestore {
+							prefetchRejected = append(prefetchRejected, tx)
+						}
+						continue
+					}
+				}
+
 				// Prefetch all values from state
 				storage := map[string][]byte{}
 				stateKeys, err := tx.StateKeys(sm)
@@ -227,40 +341,92 @@ func BuildBlock(
 			execErr = err
 		}
 
-		// Execute transactions as they become ready
+		// Execute transactions as they become ready. Txs whose StateKeys
+		// don't overlap any in-flight tx are dispatched to a worker pool
+		// sized by [MaxExecutionParallelism] via the same dependency-graph
+		// executor used for verification; only conflicting txs are
+		// serialized against each other. Each worker runs PreExecute/Execute
+		// against its own tstate sub-view, but the views are merged into
+		// the parent -- and feeManager/warpCount/CCC/conflictSet are
+		// updated -- behind a small ticketed critical section taken in
+		// stream arrival order, so the result matches a deterministic
+		// serial execution no matter how the workers interleaved.
 		ctx, executeSpan := vm.Tracer().Start(ctx, "chain.BuildBlock.Execute")
+		ex := executor.New(len(txs), r.GetMaxExecutionParallelism(), vm.GetExecutorVerifyRecorder())
+		bs := &buildCommit{
+			vm: vm, log: log, r: r, sm: sm,
+			feeManager: feeManager, ccc: ccc, ts: ts,
+			maxUnits: maxUnits, targetUnits: targetUnits,
+			nextTime: nextTime, blockContext: blockContext, vdrState: vdrState,
+			usedKeys: usedKeys, conflictSet: conflictSet,
+			b: b, results: &results, restorable: &restorable, execErr: &execErr,
+			warpCount: &warpCount, policy: policy,
+			prefetchModified: func(pctx context.Context, modifiedKeys [][]byte) {
+				if err := state.PrefetchPaths(pctx, modifiedKeys); err != nil {
+					vm.Logger().Warn("unable to prefetch paths", zap.Error(err))
+				}
+			},
+		}
+		bs.cond = sync.NewCond(&bs.mu)
 		txIndex := 0
+		ticket := 0
 		for nextTxData := range readyTxs {
 			txsAttempted++
 			next := nextTxData.tx
-			if execErr != nil {
+
+			bs.mu.Lock()
+			stop := execErr != nil
+			if stop {
+				restorable = append(restorable, next)
+			}
+			bs.mu.Unlock()
+			if stop {
+				continue
+			}
+
+			// Stop dispatching new work as soon as a cancellation arrives;
+			// everything still arriving on [readyTxs] gets restored rather
+			// than executed.
+			if canceled() {
+				bs.mu.Lock()
 				restorable = append(restorable, next)
+				bs.mu.Unlock()
 				continue
 			}
 
 			// Skip if tx is a duplicate
 			if dup.Contains(txIndex) {
+				txIndex++
 				continue
 			}
 			txIndex++
 
-			// Ensure we can process if transaction includes a warp message
-			if next.WarpMessage != nil && blockContext == nil {
-				log.Info(
-					"dropping pending warp message because no context provided",
-					zap.Stringer("txID", next.ID()),
-				)
-				restorable = append(restorable, next)
+			// Skip txs that conflict with something already included in
+			// this block, either because their own ID was declared as a
+			// conflict by an included tx, or because they themselves
+			// declare a conflict with something already included. This is
+			// a dispatch-time snapshot taken optimistically: since
+			// conflictSet is only grown inside the ticketed commit section
+			// below, a tx can race ahead of an earlier, still-executing
+			// conflicting tx and get dispatched anyway. StateKeys-level
+			// conflicts are still always caught -- the executor serializes
+			// those -- this only affects the explicit Conflicts list.
+			bs.mu.Lock()
+			skip := conflictSet.Contains(next.ID()) || conflicts(next.Conflicts, conflictSet)
+			bs.mu.Unlock()
+			if skip {
 				continue
 			}
 
-			// Skip warp message if at max
-			if next.WarpMessage != nil && warpCount == MaxWarpMessages {
+			// Ensure we can process if transaction includes a warp message
+			if next.WarpMessage != nil && blockContext == nil {
 				log.Info(
-					"dropping pending warp message because already have MaxWarpMessages",
+					"dropping pending warp message because no context provided",
 					zap.Stringer("txID", next.ID()),
 				)
+				bs.mu.Lock()
 				restorable = append(restorable, next)
+				bs.mu.Unlock()
 				continue
 			}
 
@@ -274,27 +440,8 @@ func BuildBlock(
 				)
 				continue
 			}
-			if ok, dimension := feeManager.CanConsume(nextUnits, maxUnits); !ok {
-				log.Debug(
-					"skipping tx: too many units",
-					zap.Int("dimension", int(dimension)),
-					zap.Uint64("tx", nextUnits[dimension]),
-					zap.Uint64("block units", feeManager.LastConsumed(dimension)),
-					zap.Uint64("max block units", maxUnits[dimension]),
-				)
-				restorable = append(restorable, next)
-
-				// If we are above the target for the dimension we can't consume, we will
-				// stop building. This prevents a full mempool iteration looking for the
-				// "perfect fit".
-				if feeManager.LastConsumed(dimension) >= targetUnits[dimension] {
-					execErr = errBlockFull
-				}
-				continue
-			}
 
 			// Populate required transaction state and restrict which keys can be used
-			txStart := ts.OpIndex()
 			stateKeys, err := next.StateKeys(sm)
 			if err != nil {
 				// This should not happen because we check this before
@@ -305,130 +452,30 @@ func BuildBlock(
 				)
 				continue
 			}
-			ts.SetScope(ctx, stateKeys, nextTxData.storage)
-
-			// PreExecute next to see if it is fit
-			authCUs, err := next.PreExecute(ctx, feeManager, sm, r, ts, nextTime)
-			if err != nil {
-				ts.Rollback(ctx, txStart)
-				if HandlePreExecute(log, err) {
-					restorable = append(restorable, next)
-				}
-				continue
-			}
-
-			// Verify warp message, if it exists
-			//
-			// We don't drop invalid warp messages because we must collect fees for
-			// the work the sender made us do (otherwise this would be a DoS).
-			//
-			// We wait as long as possible to verify the signature to ensure we don't
-			// spend unnecessary time on an invalid tx.
-			var warpErr error
-			if next.WarpMessage != nil {
-				// We do not check the validity of [SourceChainID] because a VM could send
-				// itself a message to trigger a chain upgrade.
-				allowed, num, denom := r.GetWarpConfig(next.WarpMessage.SourceChainID)
-				if allowed {
-					warpErr = next.WarpMessage.Signature.Verify(
-						ctx, &next.WarpMessage.UnsignedMessage, r.NetworkID(),
-						vdrState, blockContext.PChainHeight, num, denom,
-					)
-				} else {
-					warpErr = ErrDisabledChainID
-				}
-				if warpErr != nil {
-					log.Warn(
-						"warp verification failed",
-						zap.Stringer("txID", next.ID()),
-						zap.Error(warpErr),
-					)
-				}
-			}
-
-			// If execution works, keep moving forward with new state
-			//
-			// Note, these calculations must match block verification exactly
-			// otherwise they will produce a different state root.
-			coldReads := map[string]uint16{}
-			warmReads := map[string]uint16{}
-			var invalidStateKeys bool
-			for k := range stateKeys {
-				v := nextTxData.storage[k]
-				numChunks, ok := keys.NumChunks(v)
-				if !ok {
-					invalidStateKeys = true
-					break
-				}
-				if usedKeys.Contains(k) {
-					warmReads[k] = numChunks
-					continue
-				}
-				coldReads[k] = numChunks
-			}
-			if invalidStateKeys {
-				// This should not happen because we check this before
-				// adding a transaction to the mempool.
-				log.Warn("invalid tx: invalid state keys")
-				continue
-			}
-			result, err := next.Execute(
-				ctx,
-				feeManager,
-				authCUs,
-				coldReads,
-				warmReads,
-				sm,
-				r,
-				ts,
-				nextTime,
-				next.WarpMessage != nil && warpErr == nil,
-			)
-			if err != nil {
-				// Returning an error here should be avoided at all costs (can be a DoS). Rather,
-				// all units for the transaction should be consumed and a fee should be charged.
-				log.Warn("unexpected post-execution error", zap.Error(err))
-				restorable = append(restorable, next)
-				execErr = err
-				continue
-			}
 
-			// Update block with new transaction
-			b.Txs = append(b.Txs, next)
-			usedKeys.Add(stateKeys.List()...)
-			if err := feeManager.Consume(result.Consumed); err != nil {
-				execErr = err
-				continue
-			}
-			results = append(results, result)
-			if next.WarpMessage != nil {
-				if warpErr == nil {
-					// Add a bit if the warp message was verified
-					b.WarpResults.Add(uint(warpCount))
-				}
-				warpCount++
-			}
+			myTicket := ticket
+			ticket++
+			ex.Run(stateKeys, func() error {
+				bs.runTx(ctx, next, nextTxData.storage, stateKeys, nextUnits, myTicket)
+				return nil
+			})
+		}
+		_ = ex.Wait()
+		executeSpan.End()
 
-			// Prefetch path of modified keys
-			if modifiedKeys := ts.FlushModifiedKeys(false); len(modifiedKeys) > 0 {
-				pctx, prefetchPathsSpan := vm.Tracer().Start(ctx, "chain.BuildBlock.PrefetchPaths")
-				prefetchPathsSpan.SetAttributes(
-					attribute.Int("keys", len(modifiedKeys)),
-					attribute.Bool("force", false),
-				)
-				go func() {
-					defer prefetchPathsSpan.End()
+		// readyTxs is closed, so the prefetch goroutine has made its last
+		// write to prefetchRejected; safe to read without a lock.
+		restorable = append(restorable, prefetchRejected...)
 
-					// It is ok if these do not finish by the time root generation begins...
-					//
-					// If the paths of all keys are already in memory, this is a no-op.
-					if err := state.PrefetchPaths(pctx, modifiedKeys); err != nil {
-						vm.Logger().Warn("unable to prefetch paths", zap.Error(err))
-					}
-				}()
+		// If we were canceled partway through this batch, stop building
+		// immediately rather than finishing out execErr handling below; the
+		// post-loop cancellation check restores everything drained so far.
+		if canceled() {
+			if stopIndex >= 0 {
+				restorable = append(restorable, txs[stopIndex:]...)
 			}
+			break
 		}
-		executeSpan.End()
 
 		// Handle execution result
 		if execErr != nil {
@@ -445,7 +492,7 @@ func BuildBlock(
 					b.vm.Logger().Debug("transactions restored to mempool", zap.Int("count", restored))
 				}()
 				b.vm.Logger().Warn("build failed", zap.Error(execErr))
-				return nil, execErr
+				return nil, checkpoint, execErr
 			}
 
 			// Prefetch path of modified keys
@@ -470,6 +517,21 @@ func BuildBlock(
 		}
 	}
 
+	// If the build was canceled (either mid-batch, via the break above, or
+	// between batches, via the loop condition), restore every tx we drained
+	// from the mempool -- both ones we'd tentatively included and ones left
+	// in [restorable] -- and hand back a checkpoint so a retry against the
+	// same parent can skip the state reads this attempt already did.
+	if canceled() {
+		go func() {
+			prepareStreamLock.Lock()
+			restored := mempool.FinishStreaming(ctx, append(b.Txs, restorable...))
+			b.vm.Logger().Debug("transactions restored to mempool after canceled build", zap.Int("count", restored))
+		}()
+		log.Info("build canceled", zap.Int("attempted", txsAttempted))
+		return nil, checkpoint, ErrBuildCanceled
+	}
+
 	// Wait for stream preparation to finish to make
 	// sure all transactions are returned to the mempool.
 	go func() {
@@ -490,7 +552,7 @@ func BuildBlock(
 	// Perform basic validity checks to make sure the block is well-formatted
 	if len(b.Txs) == 0 {
 		if nextTime < parent.Tmstmp+r.GetMinEmptyBlockGap() {
-			return nil, fmt.Errorf("%w: allowed in %d ms", ErrNoTxs, parent.Tmstmp+r.GetMinEmptyBlockGap()-nextTime)
+			return nil, checkpoint, fmt.Errorf("%w: allowed in %d ms", ErrNoTxs, parent.Tmstmp+r.GetMinEmptyBlockGap()-nextTime)
 		}
 		vm.RecordEmptyBlockBuilt()
 	}
@@ -507,38 +569,49 @@ func BuildBlock(
 		feeKeyStr:       parentFeeManager.Bytes(),
 	})
 	if err := ts.Insert(ctx, heightKey, binary.BigEndian.AppendUint64(nil, b.Hght)); err != nil {
-		return nil, fmt.Errorf("%w: unable to insert height", err)
+		return nil, checkpoint, fmt.Errorf("%w: unable to insert height", err)
 	}
 	if err := ts.Insert(ctx, timestampKey, binary.BigEndian.AppendUint64(nil, uint64(b.Tmstmp))); err != nil {
-		return nil, fmt.Errorf("%w: unable to insert timestamp", err)
+		return nil, checkpoint, fmt.Errorf("%w: unable to insert timestamp", err)
 	}
 	if err := ts.Insert(ctx, feeKey, feeManager.Bytes()); err != nil {
-		return nil, fmt.Errorf("%w: unable to insert fees", err)
+		return nil, checkpoint, fmt.Errorf("%w: unable to insert fees", err)
 	}
 
 	// Fetch [parentView] root as late as possible to allow
 	// for async processing to complete
 	root, err := parentView.GetMerkleRoot(ctx)
 	if err != nil {
-		return nil, err
+		return nil, checkpoint, err
 	}
 	b.StateRoot = root
 
 	// Get view from [tstate] after writing all changed keys
 	view, err := ts.CreateView(ctx, parentView, vm.Tracer())
 	if err != nil {
-		return nil, err
+		return nil, checkpoint, err
 	}
 
 	// Compute block hash and marshaled representation
 	if err := b.initializeBuilt(ctx, view, results, feeManager); err != nil {
-		return nil, err
+		return nil, checkpoint, err
 	}
 
-	// Kickoff root generation
+	// Kickoff root generation, tied to [cancelCh] so that if a newer build
+	// attempt preempts us, we stop computing against (and pinning) this
+	// view instead of racing it against the next attempt's parent view.
+	rootCtx, cancelRoot := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-cancelCh:
+			cancelRoot()
+		case <-rootCtx.Done():
+		}
+	}()
 	go func() {
+		defer cancelRoot()
 		start := time.Now()
-		root, err := view.GetMerkleRoot(ctx)
+		root, err := view.GetMerkleRoot(rootCtx)
 		if err != nil {
 			log.Error("merkle root generation failed", zap.Error(err))
 			return
@@ -562,5 +635,314 @@ func BuildBlock(
 		zap.Int64("parent (t)", parent.Tmstmp),
 		zap.Int64("block (t)", b.Tmstmp),
 	)
-	return b, nil
+	return b, checkpoint, nil
+}
+
+// buildCommit holds everything a [buildCommit.runTx] worker needs to
+// execute one transaction against its own tstate sub-view and then, in a
+// ticketed critical section taken in stream arrival order, merge that view
+// into the block and update the block-level counters ([FeeManager],
+// warpCount, [CCC], conflictSet, usedKeys) exactly the way the serial
+// execute loop used to. Workers run concurrently only up through Execute;
+// everything touching shared state is serialized by ticket.
+type buildCommit struct {
+	vm           VM
+	log          logging.Logger
+	r            Rules
+	sm           StateManager
+	feeManager   *FeeManager
+	ccc          *CCC
+	ts           *tstate.TState
+	maxUnits     Dimensions
+	targetUnits  Dimensions
+	nextTime     int64
+	blockContext *smblock.Context
+	vdrState     validators.State
+	usedKeys     set.Set[string]
+	conflictSet  set.Set[ids.ID]
+	b            *StatelessBlock
+	results      *[]*Result
+	restorable   *[]*Transaction
+	execErr      *error
+	warpCount    *int
+	policy       BuilderPolicy
+
+	prefetchModified func(ctx context.Context, modifiedKeys [][]byte)
+
+	mu         sync.Mutex
+	cond       *sync.Cond
+	nextTicket int
+}
+
+// awaitTurn blocks, with bs.mu held on return, until ticket is next in
+// line. Every caller must follow up with exactly one call to advanceTurn
+// before returning from whatever it's doing -- see advanceTurn's doc.
+func (bs *buildCommit) awaitTurn(ticket int) {
+	bs.mu.Lock()
+	for bs.nextTicket != ticket {
+		bs.cond.Wait()
+	}
+}
+
+// advanceTurn bumps the ticket counter and releases bs.mu, which
+// awaitTurn must already hold. It must be called exactly once per
+// awaitTurn, on every path -- including ones that reject or fail a tx
+// before reaching the tail of runTx -- since a ticket that's never
+// advanced strands every later ticket in awaitTurn forever.
+func (bs *buildCommit) advanceTurn() {
+	bs.nextTicket++
+	bs.cond.Broadcast()
+	bs.mu.Unlock()
+}
+
+// runTx executes [next] against its own tstate sub-view (so it doesn't
+// race with concurrently-running, non-conflicting txs), then waits its
+// turn before merging the view and updating shared counters so the final
+// result matches a deterministic serial execution.
+func (bs *buildCommit) runTx(ctx context.Context, next *Transaction, storage map[string][]byte, stateKeys state.Keys, nextUnits Dimensions, myTicket int) {
+	restore := func() {
+		bs.mu.Lock()
+		*bs.restorable = append(*bs.restorable, next)
+		bs.mu.Unlock()
+	}
+	awaitTurn := func() { bs.awaitTurn(myTicket) }
+	// advanceTurn must be called exactly once, with bs.mu held, before
+	// returning from this function -- every return path below calls
+	// awaitTurn/advanceTurn (even ones that reject or fail next before
+	// ever reaching the tail of the function), since a ticket that's
+	// never consumed strands every later ticket in awaitTurn forever.
+	advanceTurn := bs.advanceTurn
+
+	tsv := bs.ts.NewView(stateKeys, storage)
+
+	// Ask the policy again now that [tsv] is populated with [next]'s
+	// declared state, in case its decision depends on values rather than
+	// just the tx itself.
+	if bs.policy != nil {
+		include, shouldRestore, err := bs.policy.ShouldConsider(ctx, next, tsv)
+		if err != nil {
+			// Every return path from here on must still take its turn
+			// (see advanceTurn's doc) so it never strands a later ticket
+			// in awaitTurn forever.
+			awaitTurn()
+			*bs.restorable = append(*bs.restorable, next)
+			*bs.execErr = err
+			advanceTurn()
+			return
+		}
+		if !include {
+			bs.policy.OnRejected(ctx, next, errPolicyRejected)
+			awaitTurn()
+			if shouldRestore {
+				*bs.restorable = append(*bs.restorable, next)
+			}
+			advanceTurn()
+			return
+		}
+	}
+
+	// PreExecute next to see if it is fit
+	authCUs, err := next.PreExecute(ctx, bs.feeManager, bs.sm, bs.r, tsv, bs.nextTime)
+	if err != nil {
+		if bs.policy != nil {
+			bs.policy.OnRejected(ctx, next, err)
+		}
+		awaitTurn()
+		if HandlePreExecute(bs.log, err) {
+			*bs.restorable = append(*bs.restorable, next)
+		}
+		advanceTurn()
+		return
+	}
+
+	// Verify warp message, if it exists
+	//
+	// We don't drop invalid warp messages because we must collect fees for
+	// the work the sender made us do (otherwise this would be a DoS).
+	//
+	// We wait as long as possible to verify the signature to ensure we don't
+	// spend unnecessary time on an invalid tx.
+	var warpErr error
+	if next.WarpMessage != nil {
+		// We do not check the validity of [SourceChainID] because a VM could send
+		// itself a message to trigger a chain upgrade.
+		allowed, num, denom := bs.r.GetWarpConfig(next.WarpMessage.SourceChainID)
+		if allowed {
+			warpErr = next.WarpMessage.Signature.Verify(
+				ctx, &next.WarpMessage.UnsignedMessage, bs.r.NetworkID(),
+				bs.vdrState, bs.blockContext.PChainHeight, num, denom,
+			)
+		} else {
+			warpErr = ErrDisabledChainID
+		}
+		if warpErr != nil {
+			bs.log.Warn(
+				"warp verification failed",
+				zap.Stringer("txID", next.ID()),
+				zap.Error(warpErr),
+			)
+		}
+	}
+
+	// If execution works, keep moving forward with new state
+	//
+	// Note, these calculations must match block verification exactly
+	// otherwise they will produce a different state root.
+	bs.mu.Lock()
+	coldReads := map[string]uint16{}
+	warmReads := map[string]uint16{}
+	var invalidStateKeys bool
+	for k := range stateKeys {
+		v := storage[k]
+		numChunks, ok := keys.NumChunks(v)
+		if !ok {
+			invalidStateKeys = true
+			break
+		}
+		if bs.usedKeys.Contains(k) {
+			warmReads[k] = numChunks
+			continue
+		}
+		coldReads[k] = numChunks
+	}
+	bs.mu.Unlock()
+	if invalidStateKeys {
+		// This should not happen because we check this before
+		// adding a transaction to the mempool.
+		bs.log.Warn("invalid tx: invalid state keys")
+		awaitTurn()
+		advanceTurn()
+		return
+	}
+
+	result, err := next.Execute(
+		ctx,
+		bs.feeManager,
+		authCUs,
+		coldReads,
+		warmReads,
+		bs.sm,
+		bs.r,
+		tsv,
+		bs.nextTime,
+		next.WarpMessage != nil && warpErr == nil,
+	)
+	if err != nil {
+		// Returning an error here should be avoided at all costs (can be a DoS). Rather,
+		// all units for the transaction should be consumed and a fee should be charged.
+		bs.log.Warn("unexpected post-execution error", zap.Error(err))
+		if bs.policy != nil {
+			bs.policy.OnRejected(ctx, next, err)
+		}
+		awaitTurn()
+		*bs.restorable = append(*bs.restorable, next)
+		*bs.execErr = err
+		advanceTurn()
+		return
+	}
+
+	// Check fine-grained resource counters before the coarser FeeManager
+	// unit check, same ordering as the serial loop.
+	nextCCCUsage := CCCUsage{
+		KeyReads:          uint64(len(stateKeys)),
+		AuthVerifications: 1,
+		SerializedBytes:   uint64(next.Size()),
+	}
+	if next.WarpMessage != nil {
+		nextCCCUsage[WarpVerifications] = 1
+	}
+
+	// Everything from here on must happen in stream arrival order so the
+	// final tstate matches a deterministic serial execution.
+	awaitTurn()
+
+	if *bs.execErr != nil {
+		advanceTurn()
+		restore()
+		return
+	}
+
+	if status := bs.ccc.Peek(nextCCCUsage); status != CCCOK {
+		if status == CCCBlockFull {
+			*bs.execErr = errBlockFull
+		}
+		advanceTurn()
+		if bs.policy != nil {
+			bs.policy.OnRejected(ctx, next, errBlockFull)
+		}
+		if status == CCCBlockFull {
+			restore()
+		}
+		return
+	}
+
+	if ok, dimension := bs.feeManager.CanConsume(nextUnits, bs.maxUnits); !ok {
+		bs.log.Debug(
+			"skipping tx: too many units",
+			zap.Int("dimension", int(dimension)),
+			zap.Uint64("tx", nextUnits[dimension]),
+		)
+		if bs.feeManager.LastConsumed(dimension) >= bs.targetUnits[dimension] {
+			*bs.execErr = errBlockFull
+		}
+		advanceTurn()
+		if bs.policy != nil {
+			bs.policy.OnRejected(ctx, next, errBlockFull)
+		}
+		restore()
+		return
+	}
+
+	if status := bs.ccc.Apply(nextCCCUsage)(); status != CCCOK {
+		if status == CCCBlockFull {
+			*bs.execErr = errBlockFull
+		}
+		advanceTurn()
+		if bs.policy != nil {
+			bs.policy.OnRejected(ctx, next, errBlockFull)
+		}
+		restore()
+		return
+	}
+
+	if err := bs.feeManager.Consume(result.Consumed); err != nil {
+		*bs.execErr = err
+		advanceTurn()
+		return
+	}
+
+	tsv.Commit()
+	bs.b.Txs = append(bs.b.Txs, next)
+	bs.usedKeys.Add(stateKeys.List()...)
+	bs.conflictSet.Add(next.ID())
+	bs.conflictSet.Add(next.Conflicts...)
+	*bs.results = append(*bs.results, result)
+	if next.WarpMessage != nil {
+		if warpErr == nil {
+			bs.b.WarpResults.Add(uint(*bs.warpCount))
+		}
+		*bs.warpCount++
+	}
+	advanceTurn()
+
+	if bs.policy != nil {
+		bs.policy.OnIncluded(ctx, next, result)
+	}
+
+	// Prefetch path of modified keys
+	if modifiedKeys := bs.ts.FlushModifiedKeys(false); len(modifiedKeys) > 0 {
+		pctx, prefetchPathsSpan := bs.vm.Tracer().Start(ctx, "chain.BuildBlock.PrefetchPaths")
+		prefetchPathsSpan.SetAttributes(
+			attribute.Int("keys", len(modifiedKeys)),
+			attribute.Bool("force", false),
+		)
+		go func() {
+			defer prefetchPathsSpan.End()
+
+			// It is ok if these do not finish by the time root generation begins...
+			//
+			// If the paths of all keys are already in memory, this is a no-op.
+			bs.prefetchModified(pctx, modifiedKeys)
+		}()
+	}
 }
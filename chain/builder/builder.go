@@ -0,0 +1,37 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package builder holds [chain.BuilderPolicy] implementations. It is
+// separate from [chain] to avoid an import cycle: a policy needs
+// [chain.Transaction] and [chain.Result] to implement the interface, while
+// [chain.BuildBlock] only needs the interface type itself.
+package builder
+
+import (
+	"context"
+
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/tstate"
+)
+
+// DefaultPolicy replicates BuildBlock's historical selection behavior:
+// every tx the mempool streams is considered, in the fee-ordered sequence
+// the mempool already hands back, subject only to the warp cap and
+// per-dimension unit caps BuildBlock itself enforces. It accepts every
+// candidate and does not track inclusion/rejection.
+type DefaultPolicy struct{}
+
+// New returns a [DefaultPolicy].
+func New() *DefaultPolicy {
+	return &DefaultPolicy{}
+}
+
+func (*DefaultPolicy) ShouldConsider(context.Context, *chain.Transaction, *tstate.TStateView) (include bool, restore bool, err error) {
+	return true, true, nil
+}
+
+func (*DefaultPolicy) OnIncluded(context.Context, *chain.Transaction, *chain.Result) {}
+
+func (*DefaultPolicy) OnRejected(context.Context, *chain.Transaction, error) {}
+
+var _ chain.BuilderPolicy = (*DefaultPolicy)(nil)
@@ -0,0 +1,24 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package chain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestNewBuildCheckpointIndependent checks that each NewBuildCheckpoint
+// call returns its own alreadyFetched map rather than a shared one --
+// mutating one returned checkpoint must never leak into another.
+func TestNewBuildCheckpointIndependent(t *testing.T) {
+	require := require.New(t)
+
+	a := NewBuildCheckpoint()
+	b := NewBuildCheckpoint()
+	a.alreadyFetched["k"] = &fetchData{nil, false, 0}
+
+	require.Len(a.alreadyFetched, 1)
+	require.Empty(b.alreadyFetched)
+}
@@ -0,0 +1,159 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package chain
+
+import (
+	"sync"
+)
+
+// CCCDimension indexes a fine-grained resource counter tracked by [CCC],
+// distinct from (and in addition to) the coarse unit dimensions tracked by
+// [FeeManager].
+type CCCDimension int
+
+const (
+	KeyReads CCCDimension = iota
+	KeyWrites
+	TriePathLength
+	AuthVerifications
+	WarpVerifications
+	SerializedBytes
+
+	numCCCDimensions
+)
+
+// CCCStatus is the outcome of applying a transaction's resource usage to a
+// [CCC].
+type CCCStatus int
+
+const (
+	// CCCOK means the transaction's resource usage was recorded and the
+	// block can keep building.
+	CCCOK CCCStatus = iota
+	// CCCOverflow means this transaction alone exceeds a per-tx cap and
+	// must be dropped; it does not affect the block's aggregate counters.
+	CCCOverflow
+	// CCCBlockFull means the block's aggregate counters would exceed the
+	// per-block cap; the builder should stop building, the same as it
+	// does today on errBlockFull.
+	CCCBlockFull
+)
+
+// CCCUsage is the fine-grained resource usage a single transaction
+// contributes to a [CCC], keyed by [CCCDimension].
+type CCCUsage [numCCCDimensions]uint64
+
+// CCC (capacity/cost checker) tracks monotonically-growing, fine-grained
+// resource counters across a block build -- state-key reads/writes,
+// modified-key trie-path lengths, auth verifications, warp-signature
+// verifications, and serialized-tx bytes -- that are too granular for
+// [FeeManager]'s unit dimensions to see individually. [CCC.Apply] hands a
+// tx's usage to a single background worker over a bounded work queue and
+// returns a future, so a caller may defer waiting on the result;
+// [BuildBlock] resolves that future straight away inside its ordered
+// commit section.
+type CCC struct {
+	perTxCap   CCCUsage
+	blockCap   CCCUsage
+	queue      chan *cccJob
+	wg         sync.WaitGroup
+
+	l        sync.Mutex
+	consumed CCCUsage
+}
+
+type cccJob struct {
+	usage CCCUsage
+	done  chan CCCStatus
+}
+
+// NewCCC returns a [CCC] with the given per-tx and per-block caps, backed by
+// a bounded work queue of size [queueSize]. Subnets tune caps via [Rules] so
+// they can be adjusted without a fork.
+func NewCCC(perTxCap, blockCap CCCUsage, queueSize int) *CCC {
+	c := &CCC{
+		perTxCap: perTxCap,
+		blockCap: blockCap,
+		queue:    make(chan *cccJob, queueSize),
+	}
+	c.wg.Add(1)
+	go c.run()
+	return c
+}
+
+func (c *CCC) run() {
+	defer c.wg.Done()
+	for job := range c.queue {
+		job.done <- c.apply(job.usage)
+	}
+}
+
+func (c *CCC) apply(usage CCCUsage) CCCStatus {
+	for d := CCCDimension(0); d < numCCCDimensions; d++ {
+		if usage[d] > c.perTxCap[d] {
+			return CCCOverflow
+		}
+	}
+
+	c.l.Lock()
+	defer c.l.Unlock()
+	for d := CCCDimension(0); d < numCCCDimensions; d++ {
+		if c.consumed[d]+usage[d] > c.blockCap[d] {
+			return CCCBlockFull
+		}
+	}
+	for d := CCCDimension(0); d < numCCCDimensions; d++ {
+		c.consumed[d] += usage[d]
+	}
+	return CCCOK
+}
+
+// Apply asynchronously records [usage] against the block's running
+// counters and returns a future that resolves to the resulting
+// [CCCStatus]. The caller is expected to call the returned func once it is
+// ready to act on the result (e.g. right before committing the tx), not
+// necessarily immediately after calling Apply.
+func (c *CCC) Apply(usage CCCUsage) func() CCCStatus {
+	job := &cccJob{usage: usage, done: make(chan CCCStatus, 1)}
+	c.queue <- job
+	return func() CCCStatus {
+		return <-job.done
+	}
+}
+
+// Peek reports whether [usage] would overflow the per-tx cap or the
+// block's current aggregate cap, without recording it. The builder calls
+// this synchronously after a tx has executed, before
+// [FeeManager.CanConsume] and [CCC.Apply], so a tx that cannot fit is
+// skipped before its usage is recorded or its view committed.
+func (c *CCC) Peek(usage CCCUsage) CCCStatus {
+	for d := CCCDimension(0); d < numCCCDimensions; d++ {
+		if usage[d] > c.perTxCap[d] {
+			return CCCOverflow
+		}
+	}
+	c.l.Lock()
+	defer c.l.Unlock()
+	for d := CCCDimension(0); d < numCCCDimensions; d++ {
+		if c.consumed[d]+usage[d] > c.blockCap[d] {
+			return CCCBlockFull
+		}
+	}
+	return CCCOK
+}
+
+// Consumed returns a copy of the current aggregate usage, keyed by
+// [CCCDimension].
+func (c *CCC) Consumed() CCCUsage {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return c.consumed
+}
+
+// Close stops the background worker once the queue drains. It must only be
+// called once all callers are done sending to [Apply].
+func (c *CCC) Close() {
+	close(c.queue)
+	c.wg.Wait()
+}
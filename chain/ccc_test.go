@@ -0,0 +1,87 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package chain
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestCCCConcurrentApply drives many concurrent Apply calls against a
+// tight block cap and checks that the aggregate counters never exceed
+// it and that exactly as many txs are admitted as the cap allows, even
+// though [CCC.apply] runs serialized on a single background goroutine
+// fed by concurrent callers.
+func TestCCCConcurrentApply(t *testing.T) {
+	require := require.New(t)
+
+	var perTxCap, blockCap CCCUsage
+	perTxCap[KeyReads] = 1
+	blockCap[KeyReads] = 50
+
+	c := NewCCC(perTxCap, blockCap, 64)
+	defer c.Close()
+
+	const n = 200
+	var wg sync.WaitGroup
+	var l sync.Mutex
+	var ok int
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			var usage CCCUsage
+			usage[KeyReads] = 1
+			status := c.Apply(usage)()
+			if status == CCCOK {
+				l.Lock()
+				ok++
+				l.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(int(blockCap[KeyReads]), ok)
+	require.Equal(blockCap[KeyReads], c.Consumed()[KeyReads])
+}
+
+// TestCCCPeekDoesNotRecord checks that concurrent Peek calls never
+// observe more usage than has actually been applied, and never mutate
+// the aggregate counters themselves.
+func TestCCCPeekDoesNotRecord(t *testing.T) {
+	require := require.New(t)
+
+	var perTxCap, blockCap CCCUsage
+	perTxCap[KeyReads] = 10
+	blockCap[KeyReads] = 10
+
+	c := NewCCC(perTxCap, blockCap, 8)
+	defer c.Close()
+
+	var usage CCCUsage
+	usage[KeyReads] = 10
+	require.Equal(CCCOK, c.Apply(usage)())
+
+	var wg sync.WaitGroup
+	const n = 50
+	statuses := make([]CCCStatus, n)
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			var probe CCCUsage
+			probe[KeyReads] = 1
+			statuses[i] = c.Peek(probe)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, status := range statuses {
+		require.Equal(CCCBlockFull, status)
+	}
+	require.Equal(blockCap[KeyReads], c.Consumed()[KeyReads])
+}
@@ -16,6 +16,14 @@ type Chunk struct {
 	Slot int64          `json:"slot"` // rounded to nearest 100ms
 	Txs  []*Transaction `json:"txs"`
 
+	// MerkleRoot commits to the erasure-coded fragments EncodeFragments
+	// splits [Txs] into: every ChunkFragment carries a MerkleProof
+	// against this root, so a validator can verify a single fragment
+	// (and sign the resulting ChunkSignature) without fetching the rest.
+	// It's part of the signed digest below so a producer can't sign one
+	// root and gossip fragments for another.
+	MerkleRoot ids.ID `json:"merkleRoot"`
+
 	Producer  ids.NodeID     `json:"producer"`
 	Signer    *bls.PublicKey `json:"signer"`
 	Signature *bls.Signature `json:"signature"`
@@ -24,8 +32,23 @@ type Chunk struct {
 	id   ids.ID
 }
 
+// body marshals just [Txs], the portion of the chunk EncodeFragments
+// erasure-codes. Everything else (Slot, Producer, ...) is small and
+// copied verbatim into every ChunkFragment instead of being coded, so
+// reconstruction only needs this much back to rebuild a Chunk.
+func (c *Chunk) body() ([]byte, error) {
+	p := codec.NewWriter(codec.CummSize(c.Txs), consts.NetworkSizeLimit)
+	p.PackInt(len(c.Txs))
+	for _, tx := range c.Txs {
+		if err := tx.Marshal(p); err != nil {
+			return nil, err
+		}
+	}
+	return p.Bytes(), p.Err()
+}
+
 func (c *Chunk) Digest() ([]byte, error) {
-	size := consts.Int64Len + consts.IntLen + codec.CummSize(c.Txs) + consts.NodeIDLen + bls.PublicKeyLen
+	size := consts.Int64Len + consts.IntLen + codec.CummSize(c.Txs) + consts.IDLen + consts.NodeIDLen + bls.PublicKeyLen
 	p := codec.NewWriter(size, consts.NetworkSizeLimit)
 
 	// Marshal transactions
@@ -37,7 +60,8 @@ func (c *Chunk) Digest() ([]byte, error) {
 		}
 	}
 
-	// Marshal signer
+	// Marshal merkle root and signer
+	p.PackID(c.MerkleRoot)
 	p.PackNodeID(c.Producer)
 	p.PackFixedBytes(bls.PublicKeyToBytes(c.Signer))
 
@@ -62,7 +86,7 @@ func (c *Chunk) Size() int {
 }
 
 func (c *Chunk) Marshal() ([]byte, error) {
-	size := consts.Int64Len + consts.IntLen + codec.CummSize(c.Txs) + consts.NodeIDLen + bls.PublicKeyLen + bls.SignatureLen
+	size := consts.Int64Len + consts.IntLen + codec.CummSize(c.Txs) + consts.IDLen + consts.NodeIDLen + bls.PublicKeyLen + bls.SignatureLen
 	p := codec.NewWriter(size, consts.NetworkSizeLimit)
 
 	// Marshal transactions
@@ -74,7 +98,8 @@ func (c *Chunk) Marshal() ([]byte, error) {
 		}
 	}
 
-	// Marshal signer
+	// Marshal merkle root and signer
+	p.PackID(c.MerkleRoot)
 	p.PackNodeID(c.Producer)
 	p.PackFixedBytes(bls.PublicKeyToBytes(c.Signer))
 	p.PackFixedBytes(bls.SignatureToBytes(c.Signature))
@@ -122,7 +147,8 @@ func UnmarshalChunk(raw []byte, parser Parser) (*Chunk, error) {
 		c.Txs = append(c.Txs, tx)
 	}
 
-	// Parse signer
+	// Parse merkle root and signer
+	p.UnpackID(true, &c.MerkleRoot)
 	p.UnpackNodeID(true, &c.Producer)
 	pk := make([]byte, bls.PublicKeyLen)
 	p.UnpackFixedBytes(bls.PublicKeyLen, &pk)
@@ -147,8 +173,9 @@ func UnmarshalChunk(raw []byte, parser Parser) (*Chunk, error) {
 }
 
 type ChunkSignature struct {
-	Chunk ids.ID `json:"chunk"`
-	Slot  int64  `json:"slot"` // used for builders that don't yet have the chunk being sequenced to verify not included before expiry
+	Chunk      ids.ID `json:"chunk"`
+	Slot       int64  `json:"slot"` // used for builders that don't yet have the chunk being sequenced to verify not included before expiry
+	MerkleRoot ids.ID `json:"merkleRoot"`
 
 	// TODO: may need NodeID to track weight? -> can get from the NodeID response
 	Signer    *bls.PublicKey `json:"signer"`
@@ -156,11 +183,12 @@ type ChunkSignature struct {
 }
 
 func (c *ChunkSignature) Marshal() ([]byte, error) {
-	size := consts.IDLen + consts.Int64Len + bls.PublicKeyLen + bls.SignatureLen
+	size := consts.IDLen + consts.Int64Len + consts.IDLen + bls.PublicKeyLen + bls.SignatureLen
 	p := codec.NewWriter(size, consts.NetworkSizeLimit)
 
 	p.PackID(c.Chunk)
 	p.PackInt64(c.Slot)
+	p.PackID(c.MerkleRoot)
 
 	p.PackFixedBytes(bls.PublicKeyToBytes(c.Signer))
 	p.PackFixedBytes(bls.SignatureToBytes(c.Signature))
@@ -168,11 +196,15 @@ func (c *ChunkSignature) Marshal() ([]byte, error) {
 	return p.Bytes(), p.Err()
 }
 
+// Digest signs over MerkleRoot rather than Chunk so a validator can sign
+// a ChunkSignature right after VerifyFragment on a single fragment --
+// it's verifying the same root the producer committed to in Chunk.Digest,
+// without needing the full chunk in hand.
 func (c *ChunkSignature) Digest() ([]byte, error) {
 	size := consts.IDLen + consts.Int64Len
 	p := codec.NewWriter(size, consts.NetworkSizeLimit)
 
-	p.PackID(c.Chunk)
+	p.PackID(c.MerkleRoot)
 	p.PackInt64(c.Slot)
 
 	return p.Bytes(), p.Err()
@@ -200,6 +232,7 @@ func UnmarshalChunkSignature(raw []byte) (*ChunkSignature, error) {
 
 	p.UnpackID(true, &c.Chunk)
 	c.Slot = p.UnpackInt64(false)
+	p.UnpackID(true, &c.MerkleRoot)
 	pk := make([]byte, bls.PublicKeyLen)
 	p.UnpackFixedBytes(bls.PublicKeyLen, &pk)
 	signer, err := bls.PublicKeyFromBytes(pk)
@@ -225,8 +258,9 @@ func UnmarshalChunkSignature(raw []byte) (*ChunkSignature, error) {
 // TODO: which height to use to verify this signature?
 // If we use the block context, validator set might change a bit too frequently?
 type ChunkCertificate struct {
-	Chunk ids.ID `json:"chunk"`
-	Slot  int64  `json:"slot"`
+	Chunk      ids.ID `json:"chunk"`
+	Slot       int64  `json:"slot"`
+	MerkleRoot ids.ID `json:"merkleRoot"`
 
 	Signers   set.Bits       `json:"signers"`
 	Signature *bls.Signature `json:"signature"`
@@ -244,7 +278,7 @@ func (c *ChunkCertificate) Expiry() int64 {
 
 func (c *ChunkCertificate) Size() int {
 	signers := c.Signers.Bytes()
-	return consts.IDLen + consts.Int64Len + codec.BytesLen(signers) + bls.SignatureLen
+	return consts.IDLen + consts.Int64Len + consts.IDLen + codec.BytesLen(signers) + bls.SignatureLen
 }
 
 func (c *ChunkCertificate) Marshal() ([]byte, error) {
@@ -252,6 +286,7 @@ func (c *ChunkCertificate) Marshal() ([]byte, error) {
 
 	p.PackID(c.Chunk)
 	p.PackInt64(c.Slot)
+	p.PackID(c.MerkleRoot)
 	p.PackBytes(c.Signers.Bytes())
 	p.PackFixedBytes(bls.SignatureToBytes(c.Signature))
 
@@ -261,17 +296,21 @@ func (c *ChunkCertificate) Marshal() ([]byte, error) {
 func (c *ChunkCertificate) MarshalPacker(p *codec.Packer) error {
 	p.PackID(c.Chunk)
 	p.PackInt64(c.Slot)
+	p.PackID(c.MerkleRoot)
 	p.PackBytes(c.Signers.Bytes())
 	p.PackFixedBytes(bls.SignatureToBytes(c.Signature))
 	return p.Err()
 }
 
 // TODO: unify with ChunkSignature
+// Digest signs over MerkleRoot, matching ChunkSignature.Digest -- the
+// aggregated signature here is just the individual ChunkSignatures
+// combined, so it has to cover the same bytes they signed.
 func (c *ChunkCertificate) Digest() ([]byte, error) {
 	size := consts.IDLen + consts.Int64Len
 	p := codec.NewWriter(size, consts.NetworkSizeLimit)
 
-	p.PackID(c.Chunk)
+	p.PackID(c.MerkleRoot)
 	p.PackInt64(c.Slot)
 
 	return p.Bytes(), p.Err()
@@ -285,6 +324,7 @@ func UnmarshalChunkCertificate(raw []byte) (*ChunkCertificate, error) {
 
 	p.UnpackID(true, &c.Chunk)
 	c.Slot = p.UnpackInt64(false)
+	p.UnpackID(true, &c.MerkleRoot)
 	var signerBytes []byte
 	p.UnpackBytes(32 /* TODO: make const */, true, &signerBytes)
 	c.Signers = set.BitsFromBytes(signerBytes)
@@ -311,6 +351,7 @@ func UnmarshalChunkCertificatePacker(p *codec.Packer) (*ChunkCertificate, error)
 
 	p.UnpackID(true, &c.Chunk)
 	c.Slot = p.UnpackInt64(false)
+	p.UnpackID(true, &c.MerkleRoot)
 	var signerBytes []byte
 	p.UnpackBytes(32 /* TODO: make const */, true, &signerBytes)
 	c.Signers = set.BitsFromBytes(signerBytes)
@@ -336,6 +377,11 @@ type FilteredChunk struct {
 	Txs         []*Transaction `json:"txs"`
 	WarpResults set.Bits64     `json:"warpResults"`
 
+	// Events holds the events emitted by each tx in [Txs] (same index),
+	// so indexers can subscribe to program activity without re-executing
+	// the chunk.
+	Events [][]Event `json:"events"`
+
 	id ids.ID
 }
 
@@ -369,5 +415,15 @@ func (c *FilteredChunk) Marshal() ([]byte, error) {
 	}
 	p.PackUint64(uint64(c.WarpResults))
 
+	// Marshal events, per-tx (same index as [c.Txs])
+	p.PackInt(len(c.Events))
+	for _, txEvents := range c.Events {
+		p.PackInt(len(txEvents))
+		for _, event := range txEvents {
+			event := event
+			event.Marshal(p)
+		}
+	}
+
 	return p.Bytes(), p.Err()
 }
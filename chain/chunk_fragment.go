@@ -0,0 +1,350 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package chain
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/consts"
+)
+
+// ChunkFragment is one Reed-Solomon-coded shard of a Chunk's body (its
+// marshaled [Txs]), gossiped instead of the full chunk so a validator
+// only has to fetch DataShards of TotalShards fragments -- and can
+// verify any single one against the producer-signed Chunk.MerkleRoot
+// via MerkleProof before bothering to collect the rest.
+type ChunkFragment struct {
+	ChunkID  ids.ID     `json:"chunkID"`
+	Slot     int64      `json:"slot"`
+	Producer ids.NodeID `json:"producer"`
+
+	Index       int `json:"index"`
+	TotalShards int `json:"totalShards"`
+	DataShards  int `json:"dataShards"`
+
+	MerkleProof [][]byte `json:"merkleProof"`
+	Payload     []byte   `json:"payload"`
+}
+
+func (f *ChunkFragment) Marshal() ([]byte, error) {
+	size := consts.IDLen + consts.Int64Len + consts.NodeIDLen + 3*consts.IntLen +
+		consts.IntLen + len(f.MerkleProof)*(consts.IntLen+sha256.Size) + codec.BytesLen(f.Payload)
+	p := codec.NewWriter(size, consts.NetworkSizeLimit)
+
+	p.PackID(f.ChunkID)
+	p.PackInt64(f.Slot)
+	p.PackNodeID(f.Producer)
+	p.PackInt(f.Index)
+	p.PackInt(f.TotalShards)
+	p.PackInt(f.DataShards)
+
+	p.PackInt(len(f.MerkleProof))
+	for _, sibling := range f.MerkleProof {
+		p.PackFixedBytes(sibling)
+	}
+	p.PackBytes(f.Payload)
+
+	return p.Bytes(), p.Err()
+}
+
+func UnmarshalChunkFragment(raw []byte) (*ChunkFragment, error) {
+	var (
+		p = codec.NewReader(raw, consts.NetworkSizeLimit)
+		f ChunkFragment
+	)
+
+	p.UnpackID(true, &f.ChunkID)
+	f.Slot = p.UnpackInt64(false)
+	p.UnpackNodeID(true, &f.Producer)
+	f.Index = p.UnpackInt(false)
+	f.TotalShards = p.UnpackInt(true)
+	f.DataShards = p.UnpackInt(true)
+
+	proofLen := p.UnpackInt(false)
+	f.MerkleProof = make([][]byte, proofLen)
+	for i := range f.MerkleProof {
+		sibling := make([]byte, sha256.Size)
+		p.UnpackFixedBytes(sha256.Size, &sibling)
+		f.MerkleProof[i] = sibling
+	}
+	p.UnpackBytes(consts.NetworkSizeLimit, true, &f.Payload)
+
+	if !p.Empty() {
+		return nil, fmt.Errorf("%w: remaining=%d", ErrInvalidObject, len(raw)-p.Offset())
+	}
+	return &f, p.Err()
+}
+
+// fragmentLeaf is the Merkle leaf hash for shard [index]'s payload,
+// binding the shard's position in (so a proof can't be replayed against
+// a different index) as well as its content.
+func fragmentLeaf(index int, payload []byte) []byte {
+	buf := make([]byte, 8+len(payload))
+	binary.BigEndian.PutUint64(buf[:8], uint64(index))
+	copy(buf[8:], payload)
+	h := sha256.Sum256(buf)
+	return h[:]
+}
+
+func hashPair(a, b []byte) []byte {
+	h := sha256.New()
+	h.Write(a)
+	h.Write(b)
+	return h.Sum(nil)
+}
+
+// merkleTree builds a binary Merkle tree over leaves (duplicating the
+// last node at each level if its width is odd) and returns the root
+// alongside, for every leaf, the sibling hash at each level needed to
+// recompute the root from that leaf alone -- i.e. its inclusion proof.
+func merkleTree(leaves [][]byte) ([]byte, [][][]byte) {
+	n := len(leaves)
+	proofs := make([][][]byte, n)
+	pos := make([]int, n)
+	for i := range pos {
+		pos[i] = i
+	}
+
+	level := make([][]byte, n)
+	copy(level, leaves)
+
+	for len(level) > 1 {
+		for leaf, p := range pos {
+			var sibling []byte
+			if p%2 == 0 {
+				if p+1 < len(level) {
+					sibling = level[p+1]
+				} else {
+					sibling = level[p]
+				}
+			} else {
+				sibling = level[p-1]
+			}
+			proofs[leaf] = append(proofs[leaf], sibling)
+		}
+
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			right := level[i]
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			next = append(next, hashPair(level[i], right))
+		}
+		level = next
+		for i := range pos {
+			pos[i] /= 2
+		}
+	}
+
+	return level[0], proofs
+}
+
+// verifyMerkleProof recomputes the root fragmentLeaf(index, payload)
+// implies under proof and reports whether it matches root.
+func verifyMerkleProof(index int, payload []byte, proof [][]byte, root []byte) bool {
+	cur := fragmentLeaf(index, payload)
+	for _, sibling := range proof {
+		if index%2 == 0 {
+			cur = hashPair(cur, sibling)
+		} else {
+			cur = hashPair(sibling, cur)
+		}
+		index /= 2
+	}
+	if len(cur) != len(root) {
+		return false
+	}
+	for i := range cur {
+		if cur[i] != root[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// splitBody pads raw to a multiple of dataShards and splits it into
+// dataShards equal-length pieces. The original length is stored as a
+// leading 4-byte prefix so ReconstructChunk can trim the padding back
+// off again.
+func splitBody(raw []byte, dataShards int) [][]byte {
+	prefixed := make([]byte, 4+len(raw))
+	binary.BigEndian.PutUint32(prefixed[:4], uint32(len(raw)))
+	copy(prefixed[4:], raw)
+
+	shardLen := (len(prefixed) + dataShards - 1) / dataShards
+	padded := make([]byte, shardLen*dataShards)
+	copy(padded, prefixed)
+
+	shards := make([][]byte, dataShards)
+	for i := 0; i < dataShards; i++ {
+		shards[i] = padded[i*shardLen : (i+1)*shardLen]
+	}
+	return shards
+}
+
+// joinBody reverses splitBody given the first dataShards shards.
+func joinBody(shards [][]byte, dataShards int) ([]byte, error) {
+	var full []byte
+	for i := 0; i < dataShards; i++ {
+		full = append(full, shards[i]...)
+	}
+	if len(full) < 4 {
+		return nil, fmt.Errorf("%w: reconstructed body too short", ErrInvalidObject)
+	}
+	n := binary.BigEndian.Uint32(full[:4])
+	if int(n) > len(full)-4 {
+		return nil, fmt.Errorf("%w: reconstructed body shorter than its length prefix", ErrInvalidObject)
+	}
+	return full[4 : 4+n], nil
+}
+
+// EncodeFragments Reed-Solomon encodes chunk's body (its marshaled
+// [Txs]) into totalShards fragments, any dataShards of which
+// ReconstructChunk can turn back into the original chunk. chunk must
+// already be producer-signed: the fragments' MerkleProof authenticates
+// against chunk.MerkleRoot, which is part of the signed Digest, so
+// EncodeFragments recomputes it from the shards and errors out if it
+// differs from chunk.MerkleRoot rather than let a caller gossip fragments
+// for a root the signature doesn't cover. An unset (ids.Empty)
+// chunk.MerkleRoot is not checked.
+func EncodeFragments(chunk *Chunk, dataShards, totalShards int) ([]*ChunkFragment, error) {
+	if dataShards <= 0 || totalShards <= dataShards {
+		return nil, fmt.Errorf("%w: dataShards=%d totalShards=%d", ErrInvalidObject, dataShards, totalShards)
+	}
+
+	chunkID, err := chunk.ID()
+	if err != nil {
+		return nil, err
+	}
+	body, err := chunk.body()
+	if err != nil {
+		return nil, err
+	}
+
+	data := splitBody(body, dataShards)
+	shards, err := rsEncode(data, totalShards)
+	if err != nil {
+		return nil, err
+	}
+
+	leaves := make([][]byte, totalShards)
+	for i, s := range shards {
+		leaves[i] = fragmentLeaf(i, s)
+	}
+	root, proofs := merkleTree(leaves)
+	var computedRoot ids.ID
+	copy(computedRoot[:], root)
+	if chunk.MerkleRoot != ids.Empty && computedRoot != chunk.MerkleRoot {
+		return nil, fmt.Errorf("%w: chunk %s signed a different merkle root than its body encodes to", ErrInvalidObject, chunkID)
+	}
+
+	frags := make([]*ChunkFragment, totalShards)
+	for i, s := range shards {
+		frags[i] = &ChunkFragment{
+			ChunkID:     chunkID,
+			Slot:        chunk.Slot,
+			Producer:    chunk.Producer,
+			Index:       i,
+			TotalShards: totalShards,
+			DataShards:  dataShards,
+			MerkleProof: proofs[i],
+			Payload:     s,
+		}
+	}
+	return frags, nil
+}
+
+// VerifyFragment checks frag's MerkleProof against root (typically a
+// producer-signed Chunk.MerkleRoot or ChunkCertificate.MerkleRoot a
+// validator already trusts), letting a fragment be accepted on its own
+// without the rest of the chunk in hand.
+func VerifyFragment(frag *ChunkFragment, root ids.ID) error {
+	if frag.Index < 0 || frag.Index >= frag.TotalShards {
+		return fmt.Errorf("%w: fragment index %d out of [0,%d)", ErrInvalidObject, frag.Index, frag.TotalShards)
+	}
+	if !verifyMerkleProof(frag.Index, frag.Payload, frag.MerkleProof, root[:]) {
+		return fmt.Errorf("%w: fragment %d of chunk %s failed merkle proof verification", ErrInvalidObject, frag.Index, frag.ChunkID)
+	}
+	return nil
+}
+
+// ReconstructChunk rebuilds a Chunk from any DataShards of its
+// TotalShards fragments. The recovered Merkle root is cross-checked
+// against every supplied fragment's own MerkleProof before being
+// trusted, so a single malicious fragment among the inputs is caught
+// rather than silently corrupting the reconstruction. Signer/Signature
+// aren't recoverable from fragments alone (they aren't part of the
+// coded payload) and are left unset -- callers that need
+// Chunk.VerifySignature should attach those from the ChunkCertificate
+// or header they received out of band.
+func ReconstructChunk(frags []*ChunkFragment, parser Parser) (*Chunk, error) {
+	if len(frags) == 0 {
+		return nil, fmt.Errorf("%w: no fragments", ErrInvalidObject)
+	}
+	first := frags[0]
+	dataShards, totalShards := first.DataShards, first.TotalShards
+
+	shards := make([][]byte, totalShards)
+	for _, f := range frags {
+		if f.ChunkID != first.ChunkID || f.DataShards != dataShards || f.TotalShards != totalShards {
+			return nil, fmt.Errorf("%w: fragment for chunk %s doesn't match chunk %s", ErrInvalidObject, f.ChunkID, first.ChunkID)
+		}
+		if f.Index < 0 || f.Index >= totalShards {
+			return nil, fmt.Errorf("%w: fragment index %d out of [0,%d)", ErrInvalidObject, f.Index, totalShards)
+		}
+		if shards[f.Index] == nil {
+			shards[f.Index] = f.Payload
+		}
+	}
+
+	full, err := rsReconstruct(shards, dataShards, totalShards)
+	if err != nil {
+		return nil, err
+	}
+
+	leaves := make([][]byte, totalShards)
+	for i, s := range full {
+		leaves[i] = fragmentLeaf(i, s)
+	}
+	root, _ := merkleTree(leaves)
+	for _, f := range frags {
+		if !verifyMerkleProof(f.Index, f.Payload, f.MerkleProof, root) {
+			return nil, fmt.Errorf("%w: fragment %d of chunk %s failed merkle proof verification", ErrInvalidObject, f.Index, f.ChunkID)
+		}
+	}
+
+	body, err := joinBody(full, dataShards)
+	if err != nil {
+		return nil, err
+	}
+
+	actionRegistry, authRegistry := parser.Registry()
+	p := codec.NewReader(body, consts.NetworkSizeLimit)
+	txCount := p.UnpackInt(true)
+	txs := make([]*Transaction, 0, txCount)
+	for i := 0; i < txCount; i++ {
+		tx, err := UnmarshalTx(p, actionRegistry, authRegistry)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+	if !p.Empty() {
+		return nil, fmt.Errorf("%w: remaining=%d", ErrInvalidObject, len(body)-p.Offset())
+	}
+
+	var merkleRoot ids.ID
+	copy(merkleRoot[:], root)
+	return &Chunk{
+		Slot:       first.Slot,
+		Txs:        txs,
+		MerkleRoot: merkleRoot,
+		Producer:   first.Producer,
+	}, p.Err()
+}
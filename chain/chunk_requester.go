@@ -0,0 +1,242 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"go.uber.org/zap"
+)
+
+const (
+	chunkFetchTimeout     = 2 * time.Second
+	chunkFetchRetries     = 8
+	chunkFetchBackoff     = 50 * time.Millisecond
+	defaultRequesterQueue = 1024
+)
+
+var errNoChunkPeers = errors.New("no peers available to fetch chunk")
+
+// ChunkFetcher is implemented by the networking layer and knows how to ask
+// a specific peer for a raw [Chunk] payload.
+type ChunkFetcher interface {
+	// FetchChunk requests [chunkID] from [nodeID] and returns the raw,
+	// marshaled chunk bytes.
+	FetchChunk(ctx context.Context, nodeID ids.NodeID, chunkID ids.ID) ([]byte, error)
+
+	// Peers returns the node IDs currently believed to have chunks
+	// available, used to spread requests across the network.
+	Peers() []ids.NodeID
+}
+
+// chunkFetch is a single outstanding height's worth of missing chunks.
+type chunkFetch struct {
+	ctx    context.Context
+	height uint64
+	certs  []*ChunkCertificate
+	ch     chan *Chunk
+}
+
+// Requester fetches the raw [Chunk] payloads referenced by a block's
+// AvailableChunks certificates that aren't yet in local storage. A bounded
+// queue of heights is drained by a pool of workers, each issuing parallel,
+// per-peer gets with a timeout and retry/backoff, so fetching for later
+// heights can overlap with processing of earlier ones.
+type Requester struct {
+	vm      VM
+	parser  Parser
+	fetcher ChunkFetcher
+
+	queue chan *chunkFetch
+
+	wg       sync.WaitGroup
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewRequester creates a [Requester] with [workers] parallel fetch workers.
+func NewRequester(vm VM, parser Parser, fetcher ChunkFetcher, workers int) *Requester {
+	r := &Requester{
+		vm:      vm,
+		parser:  parser,
+		fetcher: fetcher,
+		queue:   make(chan *chunkFetch, defaultRequesterQueue),
+		stop:    make(chan struct{}),
+	}
+	r.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go r.worker()
+	}
+	return r
+}
+
+// Request enqueues a fetch for every certificate in [certs] that does not
+// already have its raw chunk locally available, streaming each fetched
+// [Chunk] into [ch] as it arrives and closing [ch] once all have landed (or
+// the requester is stopped, or [ctx] is done). Certificates already
+// available locally are written to [ch] immediately, without going through
+// a worker.
+//
+// Callers, typically [Engine.Execute], can begin processing chunks off of
+// [ch] as soon as the first one arrives rather than waiting for the whole
+// height to be fetched. [ctx] is carried through to every FetchChunk call
+// this request spawns, so canceling it (e.g. the engine shutting down)
+// unblocks outstanding fetches instead of leaving them to run out their
+// retry budget.
+func (r *Requester) Request(ctx context.Context, height uint64, certs []*ChunkCertificate, ch chan *Chunk) {
+	remote := make([]*ChunkCertificate, 0, len(certs))
+	for _, cert := range certs {
+		if c, ok := r.vm.GetChunk(cert.Chunk); ok {
+			ch <- c
+			continue
+		}
+		remote = append(remote, cert)
+	}
+
+	if len(remote) == 0 {
+		close(ch)
+		return
+	}
+
+	select {
+	case r.queue <- &chunkFetch{ctx: ctx, height: height, certs: remote, ch: ch}:
+	case <-r.stop:
+		close(ch)
+	case <-ctx.Done():
+		close(ch)
+	}
+}
+
+// Backfill requests every height between [lastExecuted] (exclusive) and
+// [tip] (inclusive), one height at a time. It does not store what it
+// fetches: each height's chunks are received and dropped. It is intended
+// to run once on VM startup after state-sync completion, before the engine
+// begins processing the backlog of accepted-but-unexecuted heights.
+func (r *Requester) Backfill(ctx context.Context, lastExecuted, tip uint64, certsAt func(uint64) ([]*ChunkCertificate, error)) error {
+	for h := lastExecuted + 1; h <= tip; h++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		certs, err := certsAt(h)
+		if err != nil {
+			return fmt.Errorf("unable to fetch certificates at height %d: %w", h, err)
+		}
+		ch := make(chan *Chunk, len(certs))
+		r.Request(ctx, h, certs, ch)
+		for range ch {
+			// Chunks are only drained here; [Requester.fetch] does not
+			// persist them.
+		}
+	}
+	return nil
+}
+
+func (r *Requester) worker() {
+	defer r.wg.Done()
+	for {
+		select {
+		case req, ok := <-r.queue:
+			if !ok {
+				return
+			}
+			r.fetch(req)
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+func (r *Requester) fetch(req *chunkFetch) {
+	log := r.vm.Logger()
+
+	var wg sync.WaitGroup
+	wg.Add(len(req.certs))
+	for _, cert := range req.certs {
+		cert := cert
+		go func() {
+			defer wg.Done()
+			chunk, err := r.fetchChunk(req.ctx, cert)
+			if err != nil {
+				log.Warn(
+					"unable to fetch chunk",
+					zap.Stringer("chunk", cert.Chunk),
+					zap.Uint64("height", req.height),
+					zap.Error(err),
+				)
+				return
+			}
+			select {
+			case req.ch <- chunk:
+			case <-r.stop:
+			case <-req.ctx.Done():
+			}
+		}()
+	}
+	wg.Wait()
+	close(req.ch)
+}
+
+func (r *Requester) fetchChunk(ctx context.Context, cert *ChunkCertificate) (*Chunk, error) {
+	var lastErr error
+	for attempt := 0; attempt < chunkFetchRetries; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		peers := r.fetcher.Peers()
+		if len(peers) == 0 {
+			lastErr = errNoChunkPeers
+			if !sleep(ctx, chunkFetchBackoff) {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		peer := peers[attempt%len(peers)]
+
+		fetchCtx, cancel := context.WithTimeout(ctx, chunkFetchTimeout)
+		raw, err := r.fetcher.FetchChunk(fetchCtx, peer, cert.Chunk)
+		cancel()
+		if err != nil {
+			lastErr = err
+			if !sleep(ctx, chunkFetchBackoff*time.Duration(attempt+1)) {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+
+		chunk, err := UnmarshalChunk(raw, r.parser)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return chunk, nil
+	}
+	return nil, fmt.Errorf("%w: chunk=%s", lastErr, cert.Chunk)
+}
+
+// sleep waits for d, returning false early (without waiting out the rest
+// of d) if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Close stops all workers and unblocks any [Request] callers still queuing.
+func (r *Requester) Close() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+	r.wg.Wait()
+}
@@ -0,0 +1,35 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package chain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestSleepReturnsEarlyOnCtxDone checks that sleep unblocks as soon as ctx
+// is done, rather than waiting out the rest of d -- this is what lets
+// fetchChunk's retry/backoff loop exit promptly when the engine's context
+// is canceled (e.g. on Shutdown) instead of running out its retry budget.
+func TestSleepReturnsEarlyOnCtxDone(t *testing.T) {
+	require := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	require.False(sleep(ctx, time.Hour))
+	require.Less(time.Since(start), time.Second)
+}
+
+// TestSleepCompletesWithoutCancellation checks the ordinary case: with no
+// cancellation, sleep waits out the full duration and reports true.
+func TestSleepCompletesWithoutCancellation(t *testing.T) {
+	require := require.New(t)
+
+	require.True(sleep(context.Background(), time.Millisecond))
+}
@@ -0,0 +1,28 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package chain
+
+import (
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/set"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConflicts checks the pure helper both BuildBlock's dispatch-time skip
+// check and the processor's verification-time rejection (see
+// ErrConflictingTransaction) key off: whether any of a tx's declared
+// Conflicts is already present in the set of IDs the block has included.
+func TestConflicts(t *testing.T) {
+	require := require.New(t)
+
+	id1, id2, id3 := ids.GenerateTestID(), ids.GenerateTestID(), ids.GenerateTestID()
+	included := set.Of(id1, id2)
+
+	require.True(conflicts([]ids.ID{id2}, included))
+	require.False(conflicts([]ids.ID{id3}, included))
+	require.False(conflicts(nil, included))
+	require.False(conflicts([]ids.ID{id3}, set.Set[ids.ID]{}))
+}
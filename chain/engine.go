@@ -4,15 +4,23 @@ import (
 	"context"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
 	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/avalanchego/utils/set"
 	"github.com/ava-labs/hypersdk/state"
+	"github.com/ava-labs/hypersdk/tstate"
 	"go.uber.org/zap"
 )
 
+// ErrJournalRootMismatch is returned by replayJournal if rebuilding a
+// journaled entry's view doesn't reproduce the root the journal recorded
+// for it, e.g. because [tstate.TState.ExportOps]/ImportOps disagree with
+// the tstate version that originally wrote the journal.
+var ErrJournalRootMismatch = errors.New("journal replay root mismatch")
+
 type engineJob struct {
 	parentTimestamp int64
 	blk             *StatelessBlock
@@ -24,6 +32,56 @@ type output struct {
 
 	startRoot ids.ID
 	chunks    []*FilteredChunk
+
+	// ops is the tstate delta that produced [view], kept around only long
+	// enough to be journaled; it is dropped once [view] is committed.
+	ops []byte
+}
+
+const (
+	// DefaultViewCacheSize bounds how many in-memory [state.View]s the
+	// engine will keep live before forcing the oldest to commit, modeled on
+	// the "tries in memory" cap used by Ethereum clients to bound the
+	// working set pinned in front of trie compaction.
+	DefaultViewCacheSize = 128
+
+	// DefaultRejournalInterval is how often the engine flushes its dirty
+	// views to [Config.JournalPath] absent an explicit shutdown.
+	DefaultRejournalInterval = 1 * time.Minute
+)
+
+// Config tunes how aggressively [Engine] evicts and journals in-memory
+// views.
+type Config struct {
+	// ViewCacheSize is the maximum number of most-recent views kept live in
+	// memory. Once exceeded, the oldest view's root generation is forced to
+	// complete and the view is persisted + evicted.
+	ViewCacheSize int
+
+	// RejournalInterval is how often dirty views still pending a full
+	// commit are flushed to [JournalPath]. Zero disables periodic
+	// rejournaling (the journal is still written on graceful [Shutdown]).
+	RejournalInterval time.Duration
+
+	// JournalPath is where in-memory dirty views are journaled so they can
+	// be replayed on restart instead of re-executing chunks. Empty
+	// disables journaling entirely.
+	JournalPath string
+
+	// OnFilteredChunks, if set, is called with the [FilteredChunk]s
+	// produced for a height right after they're built, before they're
+	// stored in [Engine.outputs]. It lets a subscriber broadcaster (e.g.
+	// examples/litevm/rpc's websocket pub/sub server) push newly accepted
+	// chunks out to subscribers without polling the engine, and runs
+	// synchronously on the processing goroutine, so it must not block.
+	OnFilteredChunks func(height uint64, chunks []*FilteredChunk)
+}
+
+func NewConfig() Config {
+	return Config{
+		ViewCacheSize:     DefaultViewCacheSize,
+		RejournalInterval: DefaultRejournalInterval,
+	}
 }
 
 // Engine is in charge of orchestrating the execution of
@@ -31,17 +89,28 @@ type output struct {
 //
 // TODO: put in VM?
 type Engine struct {
-	vm VM
+	vm        VM
+	requester *Requester
+	cfg       Config
+	journal   *journal
 
+	ctx     context.Context
 	backlog chan *engineJob
 
 	outputsLock sync.RWMutex
 	outputs     map[uint64]*output
+	order       []uint64 // heights with a live view, oldest first
+
+	evictions         int64
+	rejournalDuration time.Duration
 }
 
-func NewEngine(vm VM, maxBacklog int) *Engine {
+func NewEngine(vm VM, maxBacklog int, requester *Requester, cfg Config) *Engine {
 	return &Engine{
-		vm: vm,
+		vm:        vm,
+		requester: requester,
+		cfg:       cfg,
+		journal:   newJournal(cfg.JournalPath),
 
 		backlog: make(chan *engineJob, maxBacklog),
 
@@ -50,6 +119,7 @@ func NewEngine(vm VM, maxBacklog int) *Engine {
 }
 
 func (e *Engine) Run(ctx context.Context) {
+	e.ctx = ctx
 	log := e.vm.Logger()
 
 	// Get last accepted state
@@ -60,8 +130,26 @@ func (e *Engine) Run(ctx context.Context) {
 	}
 	parentView = view
 
+	// Replay any views a prior shutdown journaled instead of re-executing
+	// their chunks from scratch.
+	parentView, err = e.replayJournal(ctx, parentView)
+	if err != nil {
+		panic(err)
+	}
+
+	var rejournal <-chan time.Time
+	if e.cfg.RejournalInterval > 0 {
+		ticker := time.NewTicker(e.cfg.RejournalInterval)
+		defer ticker.Stop()
+		rejournal = ticker.C
+	}
+
 	for {
 		select {
+		case <-rejournal:
+			if err := e.rejournal(ctx); err != nil {
+				log.Warn("unable to rejournal dirty views", zap.Error(err))
+			}
 		case job := <-e.backlog:
 			r := e.vm.Rules(job.blk.StatefulBlock.Timestamp)
 
@@ -106,9 +194,10 @@ func (e *Engine) Run(ctx context.Context) {
 			filteredChunks := make([]*FilteredChunk, len(chunkResults))
 			for i, chunkResult := range chunkResults {
 				var (
-					chunk = chunks[i]
-					cert  = job.blk.AvailableChunks[i]
-					txs   = make([]*Transaction, 0, len(chunkResult))
+					chunk  = chunks[i]
+					cert   = job.blk.AvailableChunks[i]
+					txs    = make([]*Transaction, 0, len(chunkResult))
+					events = make([][]Event, 0, len(chunkResult))
 
 					warpResults set.Bits64
 					warpCount   uint
@@ -119,6 +208,7 @@ func (e *Engine) Run(ctx context.Context) {
 					}
 					tx := chunk.Txs[j]
 					txs = append(txs, tx)
+					events = append(events, txResult.Events)
 					if tx.WarpMessage != nil {
 						if txResult.WarpVerified {
 							warpResults.Add(warpCount)
@@ -132,6 +222,7 @@ func (e *Engine) Run(ctx context.Context) {
 
 					Txs:         txs,
 					WarpResults: warpResults,
+					Events:      events,
 				}
 			}
 
@@ -159,6 +250,10 @@ func (e *Engine) Run(ctx context.Context) {
 				panic(err)
 			}
 
+			if e.cfg.OnFilteredChunks != nil {
+				e.cfg.OnFilteredChunks(job.blk.StatefulBlock.Height, filteredChunks)
+			}
+
 			// Create new view and kickoff generation
 			view, err := ts.ExportMerkleDBView(ctx, e.vm.Tracer(), parentView)
 			if err != nil {
@@ -180,15 +275,24 @@ func (e *Engine) Run(ctx context.Context) {
 			}()
 
 			// Store and update parent view
+			height := job.blk.StatefulBlock.Height
 			e.outputsLock.Lock()
-			e.outputs[job.blk.StatefulBlock.Height] = &output{
+			e.outputs[height] = &output{
 				view:      view,
 				startRoot: startRoot,
 				chunks:    filteredChunks,
+				ops:       ts.ExportOps(),
 			}
+			e.order = append(e.order, height)
 			e.outputsLock.Unlock()
 			parentView = view
 
+			// Enforce the view cache cap: force the oldest view(s) to
+			// finish generating their root, persist them, and evict.
+			if err := e.evictOldest(ctx); err != nil {
+				panic(err)
+			}
+
 			// TODO: persist filtered chunks we finish processing/clear old raw chunks
 		case <-ctx.Done():
 			return
@@ -196,10 +300,136 @@ func (e *Engine) Run(ctx context.Context) {
 	}
 }
 
+// evictOldest forces the oldest live views to complete root generation and
+// commit to the underlying database until at most [Config.ViewCacheSize]
+// remain in memory.
+func (e *Engine) evictOldest(ctx context.Context) error {
+	e.outputsLock.Lock()
+	defer e.outputsLock.Unlock()
+
+	for e.cfg.ViewCacheSize > 0 && len(e.order) > e.cfg.ViewCacheSize {
+		height := e.order[0]
+		out, ok := e.outputs[height]
+		if !ok {
+			e.order = e.order[1:]
+			continue
+		}
+
+		root, err := out.view.GetMerkleRoot(ctx)
+		if err != nil {
+			return err
+		}
+		db, err := e.vm.Database()
+		if err != nil {
+			return err
+		}
+		finish := db.PrepareCommit(ctx)
+		if _, err := finish(ctx, nil); err != nil {
+			return fmt.Errorf("unable to commit evicted view at height %d (root=%s): %w", height, root, err)
+		}
+
+		out.ops = nil  // no longer dirty, drop from journal on next rejournal
+		out.view = nil // committed; release it so it no longer stays in memory
+		e.order = e.order[1:]
+		e.evictions++
+		e.vm.Logger().Info("evicted view from cache", zap.Uint64("height", height), zap.Stringer("root", root))
+	}
+	return nil
+}
+
+// replayJournal rebuilds every view left behind by a prior [Shutdown],
+// oldest height first, chaining each off the last exactly like the main
+// processing loop does, instead of re-executing the chunks that produced
+// them. It returns the new parentView -- the tip of whatever it replayed,
+// or [parentView] unchanged if the journal is empty or disabled.
+func (e *Engine) replayJournal(ctx context.Context, parentView state.View) (state.View, error) {
+	entries, err := e.journal.Load()
+	if err != nil {
+		return nil, fmt.Errorf("unable to load journal: %w", err)
+	}
+	if len(entries) == 0 {
+		return parentView, nil
+	}
+
+	e.outputsLock.Lock()
+	defer e.outputsLock.Unlock()
+	for _, entry := range entries {
+		ts := tstate.New(len(entry.Ops))
+		if err := ts.ImportOps(entry.Ops); err != nil {
+			return nil, fmt.Errorf("unable to decode journal entry at height %d: %w", entry.Height, err)
+		}
+		view, err := ts.ExportMerkleDBView(ctx, e.vm.Tracer(), parentView)
+		if err != nil {
+			return nil, fmt.Errorf("unable to rebuild view at height %d: %w", entry.Height, err)
+		}
+		root, err := view.GetMerkleRoot(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to root rebuilt view at height %d: %w", entry.Height, err)
+		}
+		if root != entry.Root {
+			return nil, fmt.Errorf("%w: height %d replayed to %s, journal recorded %s", ErrJournalRootMismatch, entry.Height, root, entry.Root)
+		}
+		e.outputs[entry.Height] = &output{
+			// startRoot (the parent's root before this height's changes)
+			// isn't part of journalEntry, so it's left zero-value here;
+			// nothing currently reads it back off a replayed output.
+			view: view,
+			ops:  entry.Ops,
+		}
+		e.order = append(e.order, entry.Height)
+		parentView = view
+	}
+	e.vm.Logger().Info("replayed journaled views", zap.Int("count", len(entries)))
+	return parentView, nil
+}
+
+// rejournal flushes every view still pending a full commit to
+// [Config.JournalPath], replacing whatever was there before.
+func (e *Engine) rejournal(ctx context.Context) error {
+	start := time.Now()
+	defer func() { e.rejournalDuration = time.Since(start) }()
+
+	e.outputsLock.RLock()
+	entries := make([]*journalEntry, 0, len(e.order))
+	for _, height := range e.order {
+		out, ok := e.outputs[height]
+		if !ok || out.ops == nil {
+			continue
+		}
+		root, err := out.view.GetMerkleRoot(ctx)
+		if err != nil {
+			e.outputsLock.RUnlock()
+			return err
+		}
+		entries = append(entries, &journalEntry{Height: height, Root: root, Ops: out.ops})
+	}
+	e.outputsLock.RUnlock()
+
+	return e.journal.Rejournal(entries)
+}
+
+// Shutdown journals every dirty in-memory view so it can be replayed on
+// restart instead of re-executing its chunks, then stops the engine's
+// background work, including the [Requester]'s fetch workers -- otherwise
+// they leak, parked in their worker loop, past engine shutdown. It should
+// be called once, as part of graceful VM shutdown.
+func (e *Engine) Shutdown(ctx context.Context) error {
+	err := e.rejournal(ctx)
+	e.requester.Close()
+	return err
+}
+
 func (e *Engine) Execute(blk *StatelessBlock) {
-	// TODO: fetch chunks that don't exist (before start run) -> use a channel for the chunks so can start execution
 	chunks := make(chan *Chunk, len(blk.AvailableChunks))
 
+	// Fetch any chunks we don't already have before processing begins.
+	// [Requester.Request] streams chunks into [chunks] as they arrive (and
+	// serves already-local chunks immediately), so processing can overlap
+	// with fetching instead of blocking on the slowest peer. e.ctx is the
+	// engine's own run context (see Run), so canceling it also unblocks
+	// any fetch this call kicks off.
+	e.requester.Request(e.ctx, blk.Height(), blk.AvailableChunks, chunks)
+
 	// Enqueue job
 	e.backlog <- &engineJob{
 		blk:    blk,
@@ -213,5 +443,30 @@ func (e *Engine) Results(height uint64) (ids.ID /* StartRoot */, []ids.ID /* Exe
 }
 
 func (e *Engine) Clear(height uint64) {
-	// TODO: clear old tracking as soon as done
+	e.outputsLock.Lock()
+	defer e.outputsLock.Unlock()
+
+	delete(e.outputs, height)
+	for i, h := range e.order {
+		if h == height {
+			e.order = append(e.order[:i], e.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// Evictions returns the number of views the engine has force-committed and
+// evicted from its cache because [Config.ViewCacheSize] was exceeded.
+func (e *Engine) Evictions() int64 {
+	e.outputsLock.RLock()
+	defer e.outputsLock.RUnlock()
+	return e.evictions
+}
+
+// LastRejournalDuration returns how long the most recently completed
+// rejournal took.
+func (e *Engine) LastRejournalDuration() time.Duration {
+	e.outputsLock.RLock()
+	defer e.outputsLock.RUnlock()
+	return e.rejournalDuration
 }
\ No newline at end of file
@@ -0,0 +1,160 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package chain
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ava-labs/hypersdk/tstate"
+)
+
+// commitRequest is one tx's bid to apply its Consumed vector to the
+// chunk's shared [FeeManager]. resp carries the verdict back to the
+// [DefaultProcessor.process] task that sent it: true means the tx's view
+// should be committed, false means it must be discarded instead. done is
+// closed once that tx's [tstate.TStateView] has actually been committed
+// (or, on a false verdict, immediately, since there's nothing to commit);
+// run blocks on it before resolving any later idx, so two tsv.Commit()
+// calls against the shared [tstate.TState] never run concurrently.
+type commitRequest struct {
+	idx    int
+	result *Result
+	resp   chan bool
+	done   chan struct{}
+}
+
+// feeCommitter serializes fee-dimension consumption across a chunk's
+// otherwise-parallel tx execution, so which tx gets blamed for pushing a
+// dimension over the block limit depends only on canonical tx order --
+// never on which executor worker happened to finish first. Every tx still
+// executes speculatively and concurrently with its siblings exactly as
+// before; it just defers committing its [tstate.TStateView] until
+// feeCommitter has replied, in strict ascending tx-index order, whether
+// its Consumed vector fit.
+type feeCommitter struct {
+	feeManager  *FeeManager
+	maxUnits    Dimensions
+	skipOnError bool
+
+	// n is the number of commit calls run expects before it's drained the
+	// whole chunk -- one per tx in c.Txs, regardless of whether that tx
+	// failed upstream of fee consumption (see commit's nil-result case).
+	n int
+
+	reqs chan *commitRequest
+	stop chan struct{}
+
+	// results/fail let a rejected consumption behave exactly like every
+	// other fallible step in process(): skip mode records a nil result and
+	// moves on to the next tx, non-skip mode calls fail once and aborts
+	// every tx from here on.
+	results []*Result
+	fail    func(error)
+}
+
+func newFeeCommitter(feeManager *FeeManager, maxUnits Dimensions, skipOnError bool, results []*Result, fail func(error)) *feeCommitter {
+	return &feeCommitter{
+		feeManager:  feeManager,
+		maxUnits:    maxUnits,
+		skipOnError: skipOnError,
+		n:           len(results),
+		reqs:        make(chan *commitRequest, len(results)),
+		stop:        make(chan struct{}),
+		results:     results,
+		fail:        fail,
+	}
+}
+
+// run drains up to n commit requests, applying each's Consumed vector to
+// feeManager in ascending idx order independent of the order requests
+// actually arrive in -- later txs simply wait in pending until every
+// lower-indexed tx has been resolved. It returns once every tx has been
+// resolved, ctx is done, or Stop is called (e.g. because
+// [DefaultProcessor.process]'s executor already finished and some
+// cancelled tx never got around to sending a request at all).
+func (fc *feeCommitter) run(ctx context.Context) {
+	pending := make(map[int]*commitRequest, fc.n)
+	next := 0
+	aborted := false
+	for next < fc.n {
+		select {
+		case req := <-fc.reqs:
+			pending[req.idx] = req
+		case <-ctx.Done():
+			return
+		case <-fc.stop:
+			return
+		}
+
+		for {
+			req, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			if aborted || req.result == nil {
+				req.resp <- false
+				continue
+			}
+			if ok, d := fc.feeManager.Consume(req.result.Consumed, fc.maxUnits); !ok {
+				req.resp <- false
+				if fc.skipOnError {
+					fc.results[req.idx] = nil
+					continue
+				}
+				fc.fail(fmt.Errorf("%w: %d too large", ErrInvalidUnitsConsumed, d))
+				aborted = true
+				continue
+			}
+			req.resp <- true
+			// next's verdict must not be resolved until idx's tsv.Commit()
+			// has actually run -- otherwise two tsv.Commit() calls could
+			// race against the shared TState even though their verdicts
+			// were handed out in order.
+			select {
+			case <-req.done:
+			case <-ctx.Done():
+				return
+			case <-fc.stop:
+				return
+			}
+		}
+	}
+}
+
+// Stop lets run return even if it hasn't resolved every tx -- any tx still
+// waiting on a reply unblocks via its own ctx, not this.
+func (fc *feeCommitter) Stop() {
+	close(fc.stop)
+}
+
+// commit submits idx's execution result (nil if idx failed upstream of
+// fee consumption) for ordered application and blocks for the verdict. On
+// a true verdict it also commits tsv itself, still inside this call, so
+// that run never hands out idx+1's verdict until idx's view has actually
+// been merged into the shared [tstate.TState] -- see commitRequest.done.
+// commit never blocks past ctx being done, so a tx cancelled while
+// waiting behind a gap left by an earlier, never-committing sibling still
+// returns instead of hanging.
+func (fc *feeCommitter) commit(ctx context.Context, idx int, result *Result, tsv *tstate.TStateView) bool {
+	req := &commitRequest{idx: idx, result: result, resp: make(chan bool, 1), done: make(chan struct{})}
+	select {
+	case fc.reqs <- req:
+	case <-ctx.Done():
+		return false
+	}
+	select {
+	case ok := <-req.resp:
+		if ok {
+			tsv.Commit()
+		}
+		close(req.done)
+		return ok
+	case <-ctx.Done():
+		return false
+	}
+}
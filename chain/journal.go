@@ -0,0 +1,140 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package chain
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/consts"
+)
+
+// journalEntry is a single record in the engine's view journal: enough to
+// recreate an in-memory [state.View] without re-executing the chunks at
+// [Height].
+type journalEntry struct {
+	Height uint64
+	Root   ids.ID
+	Ops    []byte // opaque, tstate-encoded delta (see [tstate.TState.ExportOps])
+}
+
+func (e *journalEntry) Marshal() ([]byte, error) {
+	size := consts.Uint64Len + consts.IDLen + codec.BytesLen(e.Ops)
+	p := codec.NewWriter(size, consts.NetworkSizeLimit)
+	p.PackUint64(e.Height)
+	p.PackID(e.Root)
+	p.PackBytes(e.Ops)
+	return p.Bytes(), p.Err()
+}
+
+func unmarshalJournalEntry(raw []byte) (*journalEntry, error) {
+	p := codec.NewReader(raw, consts.NetworkSizeLimit)
+	var e journalEntry
+	e.Height = p.UnpackUint64(false)
+	p.UnpackID(false, &e.Root)
+	p.UnpackBytes(consts.NetworkSizeLimit, false, &e.Ops)
+	return &e, p.Err()
+}
+
+// journal is a small append-only file recording, for each in-memory view
+// the engine still has pending a full [state.Database.PrepareCommit], the
+// height/root/delta needed to replay it on restart instead of
+// re-executing the chunks at that height. It is rewritten from scratch
+// (rejournaled) on a timer and on graceful shutdown so it never grows
+// beyond the current set of dirty views.
+type journal struct {
+	path string
+
+	l sync.Mutex
+}
+
+func newJournal(path string) *journal {
+	return &journal{path: path}
+}
+
+func (j *journal) enabled() bool {
+	return j.path != ""
+}
+
+// Rejournal atomically rewrites the journal file to contain exactly
+// [entries], oldest first. Writing to a temp file and renaming over the
+// existing journal keeps a crash from ever observing a partially written
+// file.
+func (j *journal) Rejournal(entries []*journalEntry) error {
+	if !j.enabled() {
+		return nil
+	}
+	j.l.Lock()
+	defer j.l.Unlock()
+
+	tmp := j.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		b, err := entry.Marshal()
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+		lenBuf := binary.BigEndian.AppendUint32(nil, uint32(len(b)))
+		if _, err := f.Write(lenBuf); err != nil {
+			_ = f.Close()
+			return err
+		}
+		if _, err := f.Write(b); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.path)
+}
+
+// Load reads back every entry left in the journal, oldest first, so the
+// engine can replay them instead of re-executing chunks on restart.
+func (j *journal) Load() ([]*journalEntry, error) {
+	if !j.enabled() {
+		return nil, nil
+	}
+	f, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []*journalEntry
+	lenBuf := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(f, lenBuf); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		b := make([]byte, binary.BigEndian.Uint32(lenBuf))
+		if _, err := io.ReadFull(f, b); err != nil {
+			return nil, err
+		}
+		entry, err := unmarshalJournalEntry(b)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
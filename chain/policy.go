@@ -0,0 +1,46 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package chain
+
+import (
+	"context"
+
+	"github.com/ava-labs/hypersdk/tstate"
+)
+
+// BuilderPolicy lets a subnet customize transaction selection and ordering
+// during [BuildBlock] without forking this package. It is deliberately
+// small: the mempool already hands [BuildBlock] fee-ordered candidates, so
+// a policy only needs to say yes/no to each one and observe the outcome.
+//
+// The interface lives here rather than in [chain/builder] so that
+// [BuildBlock] can reference it without importing a package that, in turn,
+// imports this one for [Transaction] and [Result]; concrete policies
+// (including the default) live in [chain/builder] and import this package
+// normally.
+type BuilderPolicy interface {
+	// ShouldConsider is called up to twice per candidate tx. The prefetch
+	// goroutine calls it first with a nil [state], before any of the tx's
+	// declared state keys are read, to let a policy reject without paying
+	// for the read. If that call includes the tx, BuildBlock calls it
+	// again immediately before PreExecute with [state] scoped to exactly
+	// those keys and populated with their prefetched values.
+	//
+	// If include is false, restore controls whether the tx goes back to
+	// the mempool (true) or is dropped (false).
+	ShouldConsider(ctx context.Context, tx *Transaction, state *tstate.TStateView) (include bool, restore bool, err error)
+
+	// OnIncluded is called after [tx] is committed to the block being
+	// built, with the result of its execution.
+	OnIncluded(ctx context.Context, tx *Transaction, result *Result)
+
+	// OnRejected is called when [tx] is turned away by this policy's own
+	// ShouldConsider, fails PreExecute or Execute, or hits a unit/CCC cap,
+	// so a policy can account for throughput it didn't itself deny. Txs
+	// left out for any other reason (duplicates, declared conflicts, a
+	// warp message without a block context, invalid state keys, a failed
+	// fee consumption, or a build that has already stopped) are not
+	// reported.
+	OnRejected(ctx context.Context, tx *Transaction, reason error)
+}
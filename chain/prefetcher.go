@@ -0,0 +1,257 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package chain
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/avalanchego/database"
+	"go.uber.org/zap"
+
+	"github.com/ava-labs/hypersdk/keys"
+	"github.com/ava-labs/hypersdk/state"
+)
+
+// prefetchQueueSize bounds how many chunks a [prefetcher] will look
+// ahead by. It only needs to stay a little ahead of [DefaultProcessor.process],
+// so there's no value in buffering the whole backlog the way
+// [DefaultProcessor.input] does.
+const prefetchQueueSize = 8
+
+// NoPrefetch disables chunk-ahead state prefetching outright, regardless
+// of [PrefetcherConfig.Enabled]. It's an operational kill switch for
+// ruling prefetching in or out as the cause of a production issue
+// without a config change and restart.
+var NoPrefetch = false
+
+// PrefetcherConfig tunes [DefaultProcessor]'s lookahead state prefetcher.
+type PrefetcherConfig struct {
+	// Enabled turns prefetching on. A VM that doesn't want the extra
+	// goroutines and disk reads can leave this false.
+	Enabled bool
+
+	// Workers is how many goroutines concurrently resolve StateKeys/
+	// GetValue for chunks still sitting in [DefaultProcessor.input].
+	Workers int
+
+	// PerTxDeadline bounds how long prefetching a single tx's keys may
+	// run before being abandoned. Prefetching only ever warms
+	// [DefaultProcessor.cache], so giving up costs nothing but the wasted read.
+	PerTxDeadline time.Duration
+}
+
+// Prefetcher abstracts [prefetcher]'s chunk-ahead cache warming so a VM
+// can swap in an alternative lookahead strategy -- or, via
+// [PrefetcherConfig.Enabled]/[NoPrefetch], none at all -- without
+// [DefaultProcessor] needing to know which it's driving.
+type Prefetcher interface {
+	// Queue offers c to the prefetcher's lookahead queue. It must not
+	// block: a full or busy prefetcher should simply drop c rather than
+	// make the caller wait, since [DefaultProcessor.process] will reach c
+	// on its own soon enough regardless.
+	Queue(c *Chunk)
+
+	// Stop cancels every in-flight prefetch and waits for workers to
+	// exit. Safe to call multiple times.
+	Stop()
+}
+
+var _ Prefetcher = (*prefetcher)(nil)
+
+// prefetcher speculatively resolves and caches the state keys of chunks
+// still queued behind the one [DefaultProcessor.process] is currently
+// executing, analogous to go-ethereum's StatePrefetcher: while chunk N
+// executes, a pool of these goroutines walks chunk N+1 (and beyond, as
+// workers free up) so that by the time [DefaultProcessor.process] reaches it,
+// [DefaultProcessor.cache] is already warm and its executor goroutines skip
+// straight past keys that would otherwise cost an [im.GetValue] call.
+//
+// It never touches [DefaultProcessor.ts] or its executor and has no
+// write-visible effect on execution: its only side effect is populating
+// [DefaultProcessor.cache] under [DefaultProcessor.cacheLock], which [DefaultProcessor.process]
+// already treats as advisory -- a cache miss there just falls back to
+// [state.Immutable.GetValue] itself.
+//
+// newPrefetcher/prefetchKey both depend on VM and StateManager, so
+// exercising this beyond construction needs a real or mock VM rather
+// than a plain unit test.
+type prefetcher struct {
+	vm VM
+	sm StateManager
+	im state.Immutable
+
+	cfg PrefetcherConfig
+
+	cacheLock *sync.RWMutex
+	cache     map[string]*fetchData
+
+	// inFlight dedups concurrent fetches of the same key between prefetch
+	// workers. The first worker to see a key stores a channel here and
+	// closes it once its fetch finishes; every other worker just waits on
+	// it instead of issuing a redundant [im.GetValue].
+	// [DefaultProcessor.process] does not consult it, so it may still
+	// read a key a worker is fetching.
+	inFlight sync.Map // string -> chan struct{}
+
+	queue chan *Chunk
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// newPrefetcher creates a [prefetcher] sharing [cache]/[cacheLock] with
+// the [DefaultProcessor] it's attached to, and starts [cfg.Workers] goroutines
+// draining its lookahead queue.
+func newPrefetcher(vm VM, sm StateManager, im state.Immutable, cfg PrefetcherConfig, cacheLock *sync.RWMutex, cache map[string]*fetchData) *prefetcher {
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	p := &prefetcher{
+		vm:        vm,
+		sm:        sm,
+		im:        im,
+		cfg:       cfg,
+		cacheLock: cacheLock,
+		cache:     cache,
+		queue:     make(chan *Chunk, prefetchQueueSize),
+		stop:      make(chan struct{}),
+	}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+// Queue offers c to the prefetcher's lookahead queue. It never blocks: a
+// full queue means workers are already behind, so piling on more only
+// grows the backlog without helping, and [DefaultProcessor.process] will reach
+// c on its own soon enough regardless.
+func (p *prefetcher) Queue(c *Chunk) {
+	select {
+	case p.queue <- c:
+	case <-p.stop:
+	default:
+	}
+}
+
+// Stop cancels every in-flight prefetch and waits for workers to exit.
+// It is safe to call multiple times and safe to call concurrently with
+// [Queue].
+func (p *prefetcher) Stop() {
+	select {
+	case <-p.stop:
+		// already stopped
+	default:
+		close(p.stop)
+	}
+	p.wg.Wait()
+}
+
+func (p *prefetcher) worker() {
+	defer p.wg.Done()
+	for {
+		select {
+		case c, ok := <-p.queue:
+			if !ok {
+				return
+			}
+			p.prefetchChunk(c)
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// prefetchChunk resolves every tx in c's StateKeys and warms [p.cache]
+// for any not already cached.
+func (p *prefetcher) prefetchChunk(c *Chunk) {
+	for _, tx := range c.Txs {
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+
+		stateKeys, err := tx.StateKeys(p.sm)
+		if err != nil {
+			// process() will hit and surface this itself when it
+			// reaches tx; prefetching is best-effort, so there's
+			// nothing useful to do with the error here.
+			continue
+		}
+		ctx := context.Background()
+		cancel := func() {}
+		if p.cfg.PerTxDeadline > 0 {
+			ctx, cancel = context.WithTimeout(ctx, p.cfg.PerTxDeadline)
+		}
+		for k := range stateKeys {
+			p.prefetchKey(ctx, k)
+		}
+		cancel()
+	}
+}
+
+// prefetchKey warms [p.cache] for k, deduping against any other prefetch
+// worker already fetching it.
+func (p *prefetcher) prefetchKey(ctx context.Context, k string) {
+	if p.cached(k) {
+		p.vm.RecordPrefetchHit()
+		return
+	}
+
+	done := make(chan struct{})
+	actual, loaded := p.inFlight.LoadOrStore(k, done)
+	if loaded {
+		select {
+		case <-actual.(chan struct{}):
+		case <-p.stop:
+		}
+		return
+	}
+	defer func() {
+		p.inFlight.Delete(k)
+		close(done)
+	}()
+
+	// k may have landed in the cache between our first check and
+	// winning the inFlight race (e.g. process() fetched it directly).
+	if p.cached(k) {
+		p.vm.RecordPrefetchHit()
+		return
+	}
+
+	v, err := p.im.GetValue(ctx, []byte(k))
+	var fd *fetchData
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		fd = &fetchData{nil, false, 0}
+	case err != nil:
+		p.vm.Logger().Warn("prefetch failed", zap.String("key", k), zap.Error(err))
+		return
+	default:
+		numChunks, ok := keys.NumChunks(v)
+		if !ok {
+			p.vm.Logger().Warn("prefetch found invalid value", zap.String("key", k))
+			return
+		}
+		fd = &fetchData{v, true, numChunks}
+	}
+
+	p.cacheLock.Lock()
+	p.cache[k] = fd
+	p.cacheLock.Unlock()
+	p.vm.RecordPrefetchMiss()
+}
+
+func (p *prefetcher) cached(k string) bool {
+	p.cacheLock.RLock()
+	defer p.cacheLock.RUnlock()
+	_, ok := p.cache[k]
+	return ok
+}
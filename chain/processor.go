@@ -3,11 +3,12 @@ package chain
 import (
 	"context"
 	"errors"
-	"fmt"
 	"sync"
 
 	"github.com/ava-labs/avalanchego/database"
 	"github.com/ava-labs/avalanchego/ids"
+	smblock "github.com/ava-labs/avalanchego/snow/engine/snowman/block"
+	"github.com/ava-labs/avalanchego/utils/set"
 	"github.com/ava-labs/avalanchego/vms/platformvm/warp"
 	"github.com/ava-labs/hypersdk/executor"
 	"github.com/ava-labs/hypersdk/keys"
@@ -19,34 +20,98 @@ const numTxs = 50000 // TODO: somehow estimate this (needed to ensure no backlog
 
 var ErrNotReady = errors.New("not ready")
 
-type Processor struct {
+// ErrConflictingTransaction is returned by [DefaultProcessor.process] when a
+// chunk includes a tx whose Conflicts overlaps a tx already committed
+// earlier in the same block -- the verification-side mirror of the skip
+// builder.go's BuildBlock applies when selecting candidates. A builder
+// that skips, rather than verification that rejects, is the only
+// difference: both sides key off the same Conflicts declared on
+// [Transaction] and the same growth rule (an included tx's own ID, plus
+// everything it declares, joins the set).
+var ErrConflictingTransaction = errors.New("conflicting transaction")
+
+// Processor abstracts the chunk-execution pipeline chain.Engine drives to
+// turn a block's chunks into [FilteredChunk]s, so alternative execution
+// engines -- a parallel/optimistic one, a single-threaded one for
+// debugging, a re-execution one for tracing -- can be swapped in without
+// Engine having to know which it's driving. [DefaultProcessor] is the
+// built-in implementation; [NewParallelProcessor] and [NewSerialProcessor]
+// construct it with different concurrency, and a VM is free to provide its
+// own implementation instead.
+type Processor interface {
+	// Add queues chunk for processing. Safe to call before every chunk has
+	// been acquired; processing can start on the first one immediately.
+	Add(chunk *Chunk)
+
+	// Done signals that no further chunks will be queued.
+	Done()
+
+	// Results blocks until Done's chunks have all been processed, then
+	// returns them alongside their per-tx results, indexed the same as the
+	// chunk they came from, and the resolved outcome of every warp message
+	// encountered.
+	Results() ([]*Chunk, [][]*Result, map[ids.ID]*WarpResult, error)
+
+	// WarpResults aggregates the verification outcome of every warp
+	// message queued so far into a signer bitmap. Unlike Results, it may
+	// be called before processing completes.
+	WarpResults() set.Bits64
+
+	// Run drives the pipeline until ctx is done or every queued chunk (up
+	// to and including the one Done was called after) has been processed.
+	Run(ctx context.Context, timestamp int64, im state.Immutable, feeManager *FeeManager, r Rules, blockContext *smblock.Context)
+}
+
+var _ Processor = (*DefaultProcessor)(nil)
+
+// DefaultProcessor is hypersdk's built-in [Processor]: it executes each
+// chunk's txs through a dependency-ordered [executor.Executor], optionally
+// aided by a lookahead [Prefetcher] and a pipelined warp verifier.
+type DefaultProcessor struct {
 	vm VM
 
 	l        sync.Mutex
 	complete bool
 	err      error
 
-	timestamp    int64
-	im           state.Immutable
-	feeManager   *FeeManager
-	r            Rules
-	sm           StateManager
-	cacheLock    sync.RWMutex
-	cache        map[string]*fetchData
-	exectutor    *executor.Executor
-	ts           *tstate.TState
-	warpMessages map[ids.ID]*warpJob
-	results      []*Result
+	// cores overrides vm.GetTransactionExecutionCores() when non-zero; set
+	// by [NewSerialProcessor] to pin execution to a single worker.
+	cores int
+
+	timestamp      int64
+	im             state.Immutable
+	feeManager     *FeeManager
+	r              Rules
+	sm             StateManager
+	cacheLock      sync.RWMutex
+	cache          map[string]*fetchData // this block's overlay; discarded if the block fails
+	blockCache     *blockCache           // carried over from the previous block, may be nil
+	executionCores int
+	verifyRecorder executor.Metrics
+	ts             *tstate.TState
+	warpLock       sync.Mutex
+	warpMessages   map[ids.ID]*warpJob
+	warpVerifier   *warpVerifier
+	results        [][]*Result // results[chunkIdx][txIdx], in the order chunks were processed
+	prefetcher     Prefetcher
+
+	// conflictMu guards conflictSet, which accumulates across every chunk
+	// this block's Run processes (see ErrConflictingTransaction).
+	conflictMu  sync.Mutex
+	conflictSet set.Set[ids.ID]
 
 	input  chan *Chunk
 	output []*Chunk
 }
 
-func NewProcessor(
+// NewDefaultProcessor creates a [DefaultProcessor] that executes chunks
+// across vm.GetTransactionExecutionCores() workers, the same as
+// [NewParallelProcessor].
+func NewDefaultProcessor(
 	vm VM,
 	chunks int,
-) *Processor {
-	return &Processor{
+) *DefaultProcessor {
+	return &DefaultProcessor{
 		vm: vm,
 
 		input:  make(chan *Chunk, chunks),
@@ -54,14 +119,57 @@ func NewProcessor(
 	}
 }
 
-// warpJob is used to signal to a listner that a *warp.Message has been
-// verified.
+// NewParallelProcessor returns a [DefaultProcessor] that executes each
+// chunk's txs across vm.GetTransactionExecutionCores() workers -- the
+// concurrency [NewDefaultProcessor] already uses. It exists alongside
+// [NewSerialProcessor] so a VM can pick between the two by name instead of
+// threading a worker count through.
+func NewParallelProcessor(
+	vm VM,
+	chunks int,
+) *DefaultProcessor {
+	return NewDefaultProcessor(vm, chunks)
+}
+
+// NewSerialProcessor returns a [DefaultProcessor] pinned to a single
+// execution worker, trading throughput for a strictly sequential
+// per-chunk execution order -- useful for debugging and for re-execution
+// during tracing, where deterministic single-stepping matters more than
+// speed.
+func NewSerialProcessor(
+	vm VM,
+	chunks int,
+) *DefaultProcessor {
+	p := NewDefaultProcessor(vm, chunks)
+	p.cores = 1
+	return p
+}
+
+// warpJob tracks one tx's outstanding warp.Message verification. done is
+// closed exactly once, by whichever warpVerifier worker resolves verified;
+// closing (rather than sending a value) lets both process() and
+// [Processor.WarpResults] observe the outcome without racing to consume
+// a single channel value.
 type warpJob struct {
-	msg          *warp.Message
-	signers      int
-	verifiedChan chan bool
-	verified     bool
-	warpNum      int
+	msg      *warp.Message
+	verified bool
+	done     chan struct{}
+
+	// warpNum is this message's index within [Processor.WarpResults]'s
+	// aggregated signer bitmap.
+	warpNum int
+}
+
+// WarpResult is what [Processor.Results] reports for a tx that carried a
+// warp.Message, once its verification has resolved.
+type WarpResult struct {
+	// Verified is whether the message's BLS signature checked out against
+	// the validator set at [Processor]'s blockContext.PChainHeight.
+	Verified bool
+
+	// Num is the message's index within [Processor.WarpResults]'s
+	// aggregated signer bitmap.
+	Num int
 }
 
 type fetchData struct {
@@ -73,16 +181,74 @@ type fetchData struct {
 
 // TODO: handle mapping chunk to new chunk
 // TODO: new chunk could have warp results + results?
-// TODO: kickoff signature verification before begin execution
-func (p *Processor) process(ctx context.Context, c *Chunk) (*Chunk, error) {
-	for _, tx := range c.Txs {
+//
+// process runs every tx in c through a per-chunk [executor.Executor],
+// returning results indexed the same as c.Txs. Whether a failing tx aborts
+// the rest of the chunk or is simply marked failed and skipped is governed
+// by [Rules.GetSkipOnError]; either way, the first non-skipped error is what
+// process returns, and every sibling tx still queued or running is
+// cancelled via ctx rather than left to run against a block that's already
+// failed.
+func (p *DefaultProcessor) process(ctx context.Context, c *Chunk) ([]*Result, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var failOnce sync.Once
+	var failErr error
+	fail := func(err error) {
+		failOnce.Do(func() {
+			failErr = err
+			cancel()
+		})
+	}
+
+	skipOnError := p.r.GetSkipOnError()
+	results := make([]*Result, len(c.Txs))
+
+	// fc applies every tx's Consumed vector to p.feeManager in canonical
+	// (chunk-local) tx-index order, so which tx gets blamed for a
+	// fee-limit overrun no longer depends on which executor worker
+	// happened to finish first.
+	fc := newFeeCommitter(p.feeManager, p.r.GetMaxBlockUnits(), skipOnError, results, fail)
+	go fc.run(ctx)
+	defer fc.Stop()
+
+	e := executor.New(len(c.Txs), p.executionCores, p.verifyRecorder)
+	for i, tx := range c.Txs {
+		i, tx := i, tx
+
 		stateKeys, err := tx.StateKeys(p.sm)
 		if err != nil {
-			// TODO: don't stop, just skip
-			e.Stop()
-			return nil, nil, err
+			if skipOnError {
+				fc.commit(ctx, i, nil, nil)
+				continue
+			}
+			fail(err)
+			break
 		}
-		p.exectutor.Run(stateKeys, func() error {
+		e.Run(stateKeys, func() error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			// Reject a tx whose Conflicts overlaps a tx already committed
+			// earlier in this block, either direction, mirroring the
+			// dispatch-time skip check BuildBlock applies (see conflicts
+			// in builder.go). Unlike the builder, which is free to simply
+			// leave a conflicting tx out, a block that was built including
+			// one is invalid.
+			p.conflictMu.Lock()
+			conflicted := p.conflictSet.Contains(tx.ID()) || conflicts(tx.Conflicts, p.conflictSet)
+			p.conflictMu.Unlock()
+			if conflicted {
+				if skipOnError {
+					fc.commit(ctx, i, nil, nil)
+					return nil
+				}
+				fail(ErrConflictingTransaction)
+				return ErrConflictingTransaction
+			}
+
 			// Fetch keys from cache
 			var (
 				reads    = make(map[string]uint16, len(stateKeys))
@@ -91,13 +257,23 @@ func (p *Processor) process(ctx context.Context, c *Chunk) (*Chunk, error) {
 			)
 			p.cacheLock.RLock()
 			for k := range stateKeys {
-				if v, ok := p.cache[k]; ok {
+				v, ok := p.cache[k]
+				if !ok && p.blockCache != nil {
+					if bv, bok := p.blockCache.get(k); bok {
+						v, ok = bv, true
+						p.vm.RecordBlockCacheHit()
+					}
+				}
+				if ok {
 					reads[k] = v.chunks
 					if v.exists {
 						storage[k] = v.v
 					}
 					continue
 				}
+				if p.blockCache != nil {
+					p.vm.RecordBlockCacheMiss()
+				}
 				toLookup = append(toLookup, k)
 			}
 			p.cacheLock.RUnlock()
@@ -113,12 +289,22 @@ func (p *Processor) process(ctx context.Context, c *Chunk) (*Chunk, error) {
 						toCache[k] = &fetchData{nil, false, 0}
 						continue
 					} else if err != nil {
+						if skipOnError {
+							fc.commit(ctx, i, nil, nil)
+							return nil
+						}
+						fail(err)
 						return err
 					}
 					// We verify that the [NumChunks] is already less than the number
 					// added on the write path, so we don't need to do so again here.
 					numChunks, ok := keys.NumChunks(v)
 					if !ok {
+						if skipOnError {
+							fc.commit(ctx, i, nil, nil)
+							return nil
+						}
+						fail(ErrInvalidKeyValue)
 						return ErrInvalidKeyValue
 					}
 					reads[k] = numChunks
@@ -135,33 +321,57 @@ func (p *Processor) process(ctx context.Context, c *Chunk) (*Chunk, error) {
 
 			// Ensure we have enough funds to pay fees
 			if err := tx.PreExecute(ctx, p.feeManager, p.sm, p.r, tsv, p.timestamp); err != nil {
+				if skipOnError {
+					fc.commit(ctx, i, nil, nil)
+					return nil
+				}
+				fail(err)
 				return err
 			}
 
 			// Wait to execute transaction until we have the warp result processed.
+			// warpVerifier kicks this off as soon as Add() queues the chunk, so
+			// by the time we reach it here done is often already closed and
+			// this doesn't block at all.
 			var warpVerified bool
+			p.warpLock.Lock()
 			warpMsg, ok := p.warpMessages[tx.ID()]
+			p.warpLock.Unlock()
 			if ok {
 				select {
-				case warpVerified = <-warpMsg.verifiedChan:
+				case <-warpMsg.done:
+					warpVerified = warpMsg.verified
 				case <-ctx.Done():
 					return ctx.Err()
 				}
 			}
 			result, err := tx.Execute(ctx, p.feeManager, reads, p.sm, p.r, tsv, p.timestamp, ok && warpVerified)
 			if err != nil {
+				if skipOnError {
+					fc.commit(ctx, i, nil, nil)
+					return nil
+				}
+				fail(err)
 				return err
 			}
-			results[i] = result
 
-			// Update block metadata with units actually consumed (if more is consumed than block allows, we will non-deterministically
-			// exit with an error based on which tx over the limit is processed first)
-			if ok, d := p.feeManager.Consume(result.Consumed, p.r.GetMaxBlockUnits()); !ok {
-				return fmt.Errorf("%w: %d too large", ErrInvalidUnitsConsumed, d)
+			// Apply this tx's Consumed vector, and commit tsv to the
+			// parent [TState], in canonical tx-index order (see
+			// feeCommitter) rather than deciding here, speculatively,
+			// whether it fits -- two txs racing past this point must not
+			// have their fee-limit outcome, or their commit order, depend
+			// on which one got here first.
+			if !fc.commit(ctx, i, result, tsv) {
+				// Either this tx pushed a dimension over the block limit,
+				// or an earlier tx already did and aborted the chunk
+				// (skipOnError==false); its view must not be committed.
+				return nil
 			}
-
-			// Commit results to parent [TState]
-			tsv.Commit()
+			p.conflictMu.Lock()
+			p.conflictSet.Add(tx.ID())
+			p.conflictSet.Add(tx.Conflicts...)
+			p.conflictMu.Unlock()
+			results[i] = result
 
 			// Update key cache
 			if len(toCache) > 0 {
@@ -174,12 +384,14 @@ func (p *Processor) process(ctx context.Context, c *Chunk) (*Chunk, error) {
 			return nil
 		})
 	}
+	if err := e.Wait(); err != nil {
+		fail(err)
+	}
 
-	// Return tstate that can be used to add block-level keys to state
-	return results, ts, nil
+	return results, failErr
 }
 
-func (p *Processor) Run(ctx context.Context, timestamp int64, im state.Immutable, feeManager *FeeManager, r Rules) {
+func (p *DefaultProcessor) Run(ctx context.Context, timestamp int64, im state.Immutable, feeManager *FeeManager, r Rules, blockContext *smblock.Context) {
 	ctx, span := p.vm.Tracer().Start(ctx, "Processor.Run")
 	defer span.End()
 
@@ -189,15 +401,26 @@ func (p *Processor) Run(ctx context.Context, timestamp int64, im state.Immutable
 	p.feeManager = feeManager
 	p.r = r
 	p.sm = p.vm.StateManager()
-	p.cache = make(map[string]*fetchData, numTxs)
-	p.exectutor = executor.New(numTxs, p.vm.GetTransactionExecutionCores(), p.vm.GetExecutorVerifyRecorder())
-	p.ts = tstate.New(numTxs * 2)
-	p.warpMessages = map[ids.ID]*warpJob{}
-	p.results = make([]*Result, numTxs)
 
-	// TODO: put this in the right spot:
-	if err := p.exectutor.Wait(); err != nil {
-		return nil, nil, err
+	estimate := p.vm.EstimateBlockTxs()
+	if estimate <= 0 {
+		estimate = numTxs
+	}
+	p.cache = make(map[string]*fetchData, estimate)
+	p.blockCache = p.vm.GetBlockCache()
+	p.executionCores = p.vm.GetTransactionExecutionCores()
+	if p.cores > 0 {
+		p.executionCores = p.cores
+	}
+	p.verifyRecorder = p.vm.GetExecutorVerifyRecorder()
+	p.ts = tstate.New(estimate * 2)
+	p.conflictSet = set.NewSet[ids.ID](0)
+	p.warpMessages = map[ids.ID]*warpJob{}
+	p.warpVerifier = newWarpVerifier(p.vm, p.r, blockContext, p.vm.GetWarpVerifyCores(), &p.warpLock, p.warpMessages)
+	defer p.warpVerifier.Stop()
+	if cfg := p.vm.GetPrefetcherConfig(); !NoPrefetch && cfg.Enabled {
+		p.prefetcher = newPrefetcher(p.vm, p.sm, p.im, cfg, &p.cacheLock, p.cache)
+		defer p.prefetcher.Stop()
 	}
 
 	// Handle chunks
@@ -207,7 +430,21 @@ func (p *Processor) Run(ctx context.Context, timestamp int64, im state.Immutable
 			if !ok {
 				p.l.Lock()
 				p.complete = true
+				succeeded := p.err == nil
 				p.l.Unlock()
+
+				// Promote this block's cache overlay into the carry-over
+				// [blockCache] only once the whole block has succeeded --
+				// an overlay built against a block that failed partway
+				// through may hold reads speculatively issued past the
+				// point of failure and must not leak into the next block.
+				if succeeded && p.blockCache != nil {
+					p.cacheLock.RLock()
+					for k, v := range p.cache {
+						p.blockCache.put(k, v)
+					}
+					p.cacheLock.RUnlock()
+				}
 				return
 			}
 
@@ -216,20 +453,23 @@ func (p *Processor) Run(ctx context.Context, timestamp int64, im state.Immutable
 				p.l.Unlock()
 				continue
 			}
+			p.l.Unlock()
+
+			results, err := p.process(ctx, c)
 
-			filtered, err := p.process(ctx, c)
 			p.l.Lock()
 			if err != nil && p.err == nil {
-				p.err = ctx.Err()
+				p.err = err
 				p.l.Unlock()
 				continue
 			}
-			p.output = append(p.output, filtered)
+			p.output = append(p.output, c)
+			p.results = append(p.results, results)
 			p.l.Unlock()
 
 		case <-ctx.Done():
 			p.l.Lock()
-			if p.err != nil {
+			if p.err == nil {
 				p.err = ctx.Err()
 			}
 			p.l.Unlock()
@@ -239,24 +479,74 @@ func (p *Processor) Run(ctx context.Context, timestamp int64, im state.Immutable
 }
 
 // Allows processing to start before all chunks are acquired.
-func (p *Processor) Add(chunk *Chunk) {
+func (p *DefaultProcessor) Add(chunk *Chunk) {
 	p.input <- chunk
+	if p.prefetcher != nil {
+		// Best-effort lookahead: chunk isn't guaranteed to be N+1 relative
+		// to whatever [process] is currently on, just "not yet processed",
+		// which is all the prefetcher needs to be useful.
+		p.prefetcher.Queue(chunk)
+	}
+	if p.warpVerifier != nil {
+		p.warpVerifier.Queue(chunk)
+	}
 }
 
-func (p *Processor) Done() {
+func (p *DefaultProcessor) Done() {
 	close(p.input)
 }
 
-// TODO: figure out how to return warp?
-func (p *Processor) Results() ([]*Chunk, error) {
+// Reset invalidates p's carry-over [blockCache] if rootHash no longer
+// matches the parent root it was last built against (e.g. a reorg moved
+// the accepted parent to a different fork). A VM should call this before
+// handing the same [blockCache] to a [DefaultProcessor] for a new block.
+// A no-op if p was constructed without a block cache.
+func (p *DefaultProcessor) Reset(rootHash ids.ID) {
+	if p.blockCache != nil {
+		p.blockCache.Reset(rootHash)
+	}
+}
+
+func (p *DefaultProcessor) Results() ([]*Chunk, [][]*Result, map[ids.ID]*WarpResult, error) {
 	p.l.Lock()
 	defer p.l.Unlock()
 
 	if !p.complete {
-		return nil, ErrNotReady
+		return nil, nil, nil, ErrNotReady
 	}
 	if p.err != nil {
-		return nil, p.err
+		return nil, nil, nil, p.err
+	}
+
+	p.warpLock.Lock()
+	warpResults := make(map[ids.ID]*WarpResult, len(p.warpMessages))
+	for txID, job := range p.warpMessages {
+		warpResults[txID] = &WarpResult{Verified: job.verified, Num: job.warpNum}
+	}
+	p.warpLock.Unlock()
+
+	return p.output, p.results, warpResults, p.err
+}
+
+// WarpResults aggregates the verification outcome of every warp message
+// queued so far into a signer bitmap indexed by each [WarpResult.Num], for
+// the block builder to attach to the block it's assembling. Unlike
+// [Processor.Results], it may be called before processing completes: a
+// message whose verification hasn't resolved yet is simply left unset,
+// the same way it would be if it had resolved to unverified.
+func (p *DefaultProcessor) WarpResults() set.Bits64 {
+	p.warpLock.Lock()
+	defer p.warpLock.Unlock()
+
+	var bits set.Bits64
+	for _, job := range p.warpMessages {
+		select {
+		case <-job.done:
+			if job.verified {
+				bits.Add(uint(job.warpNum))
+			}
+		default:
+		}
 	}
-	return p.output, p.err
+	return bits
 }
@@ -0,0 +1,212 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package chain
+
+import "fmt"
+
+// Minimal systematic Reed-Solomon codec over GF(256), backing
+// EncodeFragments/ReconstructChunk. Self-contained rather than a new
+// dependency: a chunk body is small enough that the naive O(shards^2)
+// matrix multiply here is not a bottleneck next to BLS verification.
+
+// gfPoly is the QR-code GF(256) reduction polynomial
+// (x^8 + x^4 + x^3 + x^2 + 1), used to build the log/exp tables below.
+const gfPoly = 0x11d
+
+var (
+	gfExpTab [510]byte
+	gfLogTab [256]int
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExpTab[i] = byte(x)
+		gfLogTab[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= gfPoly
+		}
+	}
+	for i := 255; i < 510; i++ {
+		gfExpTab[i] = gfExpTab[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExpTab[gfLogTab[a]+gfLogTab[b]]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExpTab[(gfLogTab[a]-gfLogTab[b]+255)%255]
+}
+
+// vandermonde returns a [rows]x[cols] Vandermonde matrix, evaluating
+// x^0..x^(cols-1) at a distinct non-zero point per row. Any [cols] of
+// its [rows] rows are linearly independent, which is what lets
+// buildEncodeMatrix turn it into a systematic encode/decode matrix.
+func vandermonde(rows, cols int) [][]byte {
+	m := make([][]byte, rows)
+	for r := 0; r < rows; r++ {
+		m[r] = make([]byte, cols)
+		point := byte(r + 1) // skip 0: x^0..x^(cols-1) at point=0 is all-zero past column 0
+		p := byte(1)
+		for c := 0; c < cols; c++ {
+			m[r][c] = p
+			p = gfMul(p, point)
+		}
+	}
+	return m
+}
+
+// invert returns m's inverse via Gauss-Jordan elimination over GF(256).
+func invert(m [][]byte) ([][]byte, error) {
+	n := len(m)
+	aug := make([][]byte, n)
+	for r := 0; r < n; r++ {
+		aug[r] = make([]byte, 2*n)
+		copy(aug[r], m[r])
+		aug[r][n+r] = 1
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := -1
+		for r := col; r < n; r++ {
+			if aug[r][col] != 0 {
+				pivot = r
+				break
+			}
+		}
+		if pivot == -1 {
+			return nil, fmt.Errorf("%w: singular reed-solomon matrix", ErrInvalidObject)
+		}
+		aug[col], aug[pivot] = aug[pivot], aug[col]
+
+		inv := gfDiv(1, aug[col][col])
+		for c := 0; c < 2*n; c++ {
+			aug[col][c] = gfMul(aug[col][c], inv)
+		}
+		for r := 0; r < n; r++ {
+			if r == col || aug[r][col] == 0 {
+				continue
+			}
+			factor := aug[r][col]
+			for c := 0; c < 2*n; c++ {
+				aug[r][c] ^= gfMul(factor, aug[col][c])
+			}
+		}
+	}
+
+	out := make([][]byte, n)
+	for r := 0; r < n; r++ {
+		out[r] = aug[r][n:]
+	}
+	return out, nil
+}
+
+// buildEncodeMatrix returns a [totalShards]x[dataShards] matrix whose
+// top [dataShards] rows are the identity -- so the first [dataShards]
+// output shards of rsEncode are exactly the input shards (systematic)
+// and the remaining rows are the parity-computing rows.
+func buildEncodeMatrix(dataShards, totalShards int) ([][]byte, error) {
+	vm := vandermonde(totalShards, dataShards)
+	topInv, err := invert(vm[:dataShards])
+	if err != nil {
+		return nil, err
+	}
+
+	m := make([][]byte, totalShards)
+	for r := 0; r < totalShards; r++ {
+		m[r] = make([]byte, dataShards)
+		for c := 0; c < dataShards; c++ {
+			var sum byte
+			for k := 0; k < dataShards; k++ {
+				sum ^= gfMul(vm[r][k], topInv[k][c])
+			}
+			m[r][c] = sum
+		}
+	}
+	return m, nil
+}
+
+// rsEncode splits data (already chunked into dataShards equal-length
+// pieces) into totalShards shards, the first dataShards of which equal
+// data verbatim.
+func rsEncode(data [][]byte, totalShards int) ([][]byte, error) {
+	dataShards := len(data)
+	matrix, err := buildEncodeMatrix(dataShards, totalShards)
+	if err != nil {
+		return nil, err
+	}
+
+	shardLen := len(data[0])
+	shards := make([][]byte, totalShards)
+	for r := 0; r < totalShards; r++ {
+		shards[r] = make([]byte, shardLen)
+		for p := 0; p < shardLen; p++ {
+			var sum byte
+			for k := 0; k < dataShards; k++ {
+				if matrix[r][k] != 0 {
+					sum ^= gfMul(matrix[r][k], data[k][p])
+				}
+			}
+			shards[r][p] = sum
+		}
+	}
+	return shards, nil
+}
+
+// rsReconstruct recovers every shard (data and parity) given a sparse
+// [totalShards]-length slice with at least dataShards non-nil entries.
+func rsReconstruct(shards [][]byte, dataShards, totalShards int) ([][]byte, error) {
+	matrix, err := buildEncodeMatrix(dataShards, totalShards)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := make([][]byte, 0, dataShards)
+	rows := make([]int, 0, dataShards)
+	shardLen := 0
+	for i, s := range shards {
+		if s == nil {
+			continue
+		}
+		sub = append(sub, matrix[i])
+		rows = append(rows, i)
+		shardLen = len(s)
+		if len(sub) == dataShards {
+			break
+		}
+	}
+	if len(sub) < dataShards {
+		return nil, fmt.Errorf("%w: have %d of %d shards required to reconstruct", ErrInvalidObject, len(sub), dataShards)
+	}
+
+	subInv, err := invert(sub)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([][]byte, dataShards)
+	for d := 0; d < dataShards; d++ {
+		data[d] = make([]byte, shardLen)
+		for p := 0; p < shardLen; p++ {
+			var sum byte
+			for k, r := range rows {
+				if subInv[d][k] != 0 {
+					sum ^= gfMul(subInv[d][k], shards[r][p])
+				}
+			}
+			data[d][p] = sum
+		}
+	}
+
+	return rsEncode(data, totalShards)
+}
@@ -0,0 +1,63 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package chain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestRSEncodeReconstructRoundTrip checks that rsReconstruct recovers the
+// exact shard set rsEncode produced from only dataShards of the totalShards
+// available, the minimum erasure-coded dissemination relies on to
+// reassemble a chunk from a partial set of fragments.
+func TestRSEncodeReconstructRoundTrip(t *testing.T) {
+	require := require.New(t)
+
+	const dataShards, totalShards = 4, 8
+	data := [][]byte{
+		[]byte("aaaa"),
+		[]byte("bbbb"),
+		[]byte("cccc"),
+		[]byte("dddd"),
+	}
+
+	shards, err := rsEncode(data, totalShards)
+	require.NoError(err)
+	require.Len(shards, totalShards)
+
+	// Drop all but dataShards of them, simulating fragments that were
+	// never received.
+	sparse := make([][]byte, totalShards)
+	copy(sparse, shards)
+	for i := dataShards; i < totalShards; i++ {
+		sparse[i] = nil
+	}
+	sparse[1] = nil
+	sparse[totalShards-1] = shards[totalShards-1]
+
+	reconstructed, err := rsReconstruct(sparse, dataShards, totalShards)
+	require.NoError(err)
+	require.Equal(shards, reconstructed)
+}
+
+// TestRSReconstructInsufficientShards checks that reconstruction fails
+// cleanly, rather than silently returning garbage, when fewer than
+// dataShards shards are available.
+func TestRSReconstructInsufficientShards(t *testing.T) {
+	require := require.New(t)
+
+	const dataShards, totalShards = 4, 8
+	data := [][]byte{[]byte("aaaa"), []byte("bbbb"), []byte("cccc"), []byte("dddd")}
+	shards, err := rsEncode(data, totalShards)
+	require.NoError(err)
+
+	sparse := make([][]byte, totalShards)
+	sparse[0] = shards[0]
+	sparse[1] = shards[1]
+
+	_, err = rsReconstruct(sparse, dataShards, totalShards)
+	require.Error(err)
+}
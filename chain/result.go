@@ -8,11 +8,46 @@ import (
 	"github.com/ava-labs/hypersdk/consts"
 )
 
+// Event is a structured, indexable log emitted by a program during
+// execution via the `emit_event` host import. It mirrors the shape of an
+// EVM log: an address, a set of topics (the first of which is the
+// signature topic computed by x/programs/abi), and opaque data.
+type Event struct {
+	Address codec.Address
+	Topics  [][32]byte
+	Data    []byte
+}
+
+func (e *Event) Marshal(p *codec.Packer) {
+	p.PackAddress(e.Address)
+	p.PackInt(len(e.Topics))
+	for _, topic := range e.Topics {
+		t := topic
+		p.PackFixedBytes(t[:])
+	}
+	p.PackBytes(e.Data)
+}
+
+func UnmarshalEvent(p *codec.Packer) (*Event, error) {
+	var e Event
+	p.UnpackAddress(&e.Address)
+	topicCount := p.UnpackInt(false)
+	e.Topics = make([][32]byte, topicCount)
+	for i := range e.Topics {
+		var topic []byte
+		p.UnpackFixedBytes(32, &topic)
+		copy(e.Topics[i][:], topic)
+	}
+	p.UnpackBytes(consts.MaxInt, false, &e.Data)
+	return &e, p.Err()
+}
+
 type Result struct {
 	Success     bool
 	Units       uint64
 	Output      []byte
 	WarpMessage []byte
+	Events      []Event
 }
 
 func (r *Result) Marshal(p *codec.Packer) {
@@ -20,6 +55,11 @@ func (r *Result) Marshal(p *codec.Packer) {
 	p.PackUint64(r.Units)
 	p.PackBytes(r.Output)
 	p.PackBytes(r.WarpMessage)
+	p.PackInt(len(r.Events))
+	for _, event := range r.Events {
+		event := event
+		event.Marshal(p)
+	}
 }
 
 func MarshalResults(src []*Result) ([]byte, error) {
@@ -46,6 +86,17 @@ func UnmarshalResult(p *codec.Packer) (*Result, error) {
 		// Enforce object standardization
 		result.WarpMessage = nil
 	}
+	eventCount := p.UnpackInt(false)
+	if eventCount > 0 {
+		result.Events = make([]Event, eventCount)
+		for i := range result.Events {
+			event, err := UnmarshalEvent(p)
+			if err != nil {
+				return nil, err
+			}
+			result.Events[i] = *event
+		}
+	}
 	return result, p.Err()
 }
 
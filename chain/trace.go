@@ -0,0 +1,154 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package chain
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/keys"
+	"github.com/ava-labs/hypersdk/state"
+	"github.com/ava-labs/hypersdk/tstate"
+	"github.com/ava-labs/hypersdk/x/programs/program"
+)
+
+// TraceConfig controls what DebugTraceTx/DebugTraceChunk capture while
+// replaying a transaction. Its one knob defaults to off so a replay that
+// only wants units/state-key info doesn't pay for WASM call capture it
+// won't use.
+type TraceConfig struct {
+	// ProgramCalls attaches a program.Tracer to any action that supports
+	// one (see tracedAction) so ActionTrace.Calls is populated with the
+	// action's `x/programs/host` call trace.
+	ProgramCalls bool
+}
+
+// ActionTrace is the structured trace of a single tx replayed under a
+// TraceConfig: the keys it touched, what it consumed, and -- for an
+// action that runs WASM via program.CallContext -- the host-call trace
+// captured from it.
+type ActionTrace struct {
+	TxID ids.ID
+
+	StateKeysRead    []string
+	StateKeysWritten []string
+
+	Result *Result
+
+	// Calls is nil unless cfg.ProgramCalls was set and the action
+	// implements tracedAction; a plain (non-program) action has nothing
+	// to report here.
+	Calls []program.CallTrace
+
+	Err string
+}
+
+// tracedAction is implemented by actions that can run WASM program calls
+// via x/programs/program.CallContext and so have something to attach a
+// program.Tracer to. Actions without WASM calls (e.g. a plain transfer)
+// don't implement it, and ReplayTx simply leaves ActionTrace.Calls nil
+// for them.
+type tracedAction interface {
+	SetTracer(program.Tracer)
+}
+
+// ReplayTx re-executes tx against im at timestamp the same way
+// Processor.process does -- same StateKeys/PreExecute/Execute sequence --
+// but against a scratch TState view instead of the one the block builder
+// or processor is using, so a debug replay can never mutate state a
+// node has already committed to, and can be run repeatedly (e.g. once
+// per explored trace option) without side effects.
+func ReplayTx(
+	ctx context.Context,
+	cfg TraceConfig,
+	tx *Transaction,
+	feeManager *FeeManager,
+	sm StateManager,
+	r Rules,
+	im state.Immutable,
+	timestamp int64,
+) *ActionTrace {
+	trace := &ActionTrace{TxID: tx.ID()}
+
+	stateKeys, err := tx.StateKeys(sm)
+	if err != nil {
+		trace.Err = err.Error()
+		return trace
+	}
+	for k, perm := range stateKeys {
+		if perm.Has(state.Write) || perm.Has(state.Allocate) {
+			trace.StateKeysWritten = append(trace.StateKeysWritten, k)
+		} else {
+			trace.StateKeysRead = append(trace.StateKeysRead, k)
+		}
+	}
+
+	reads := make(map[string]uint16, len(stateKeys))
+	storage := make(map[string][]byte, len(stateKeys))
+	for k := range stateKeys {
+		v, err := im.GetValue(ctx, []byte(k))
+		if err != nil {
+			if !errors.Is(err, database.ErrNotFound) {
+				trace.Err = err.Error()
+				return trace
+			}
+			reads[k] = 0
+			continue
+		}
+		numChunks, ok := keys.NumChunks(v)
+		if !ok {
+			trace.Err = ErrInvalidKeyValue.Error()
+			return trace
+		}
+		reads[k] = numChunks
+		storage[k] = v
+	}
+
+	ts := tstate.New(1)
+	tsv := ts.NewView(stateKeys, storage)
+
+	var tracer *program.CallTracer
+	if cfg.ProgramCalls {
+		if ta, ok := any(tx.Action).(tracedAction); ok {
+			tracer = program.NewCallTracer()
+			ta.SetTracer(tracer)
+		}
+	}
+
+	if err := tx.PreExecute(ctx, feeManager, sm, r, tsv, timestamp); err != nil {
+		trace.Err = err.Error()
+		return trace
+	}
+	result, err := tx.Execute(ctx, feeManager, reads, sm, r, tsv, timestamp, false)
+	if err != nil {
+		trace.Err = err.Error()
+		return trace
+	}
+	trace.Result = result
+	if tracer != nil {
+		trace.Calls = tracer.Calls()
+	}
+	return trace
+}
+
+// ReplayChunk calls ReplayTx for every tx in c, in order, against im
+// with c.Slot as timestamp -- what DebugTraceChunk serves over the
+// `debug` JSON-RPC namespace.
+func ReplayChunk(
+	ctx context.Context,
+	cfg TraceConfig,
+	c *Chunk,
+	feeManager *FeeManager,
+	sm StateManager,
+	r Rules,
+	im state.Immutable,
+) []*ActionTrace {
+	traces := make([]*ActionTrace, 0, len(c.Txs))
+	for _, tx := range c.Txs {
+		traces = append(traces, ReplayTx(ctx, cfg, tx, feeManager, sm, r, im, c.Slot))
+	}
+	return traces
+}
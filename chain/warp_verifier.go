@@ -0,0 +1,168 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package chain
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ava-labs/avalanchego/ids"
+	smblock "github.com/ava-labs/avalanchego/snow/engine/snowman/block"
+	"github.com/ava-labs/avalanchego/snow/validators"
+	"go.uber.org/zap"
+)
+
+// warpVerifyQueueSize bounds how many outstanding warp.Message checks a
+// [warpVerifier] will buffer. It only needs to stay ahead of
+// [DefaultProcessor.process] the way [prefetchQueueSize] does for state reads,
+// so it's sized off the same per-block tx budget as everything else in
+// [Processor].
+const warpVerifyQueueSize = numTxs
+
+// warpVerifier dispatches BLS verification for every tx.WarpMessage
+// [Processor.Add] queues to a bounded pool of workers, resolving it well
+// before [DefaultProcessor.process]'s executor goroutine reaches that tx --
+// the "kickoff signature verification before begin execution" TODO
+// process() used to carry. Each job's result is written to
+// warpJob.verified and warpJob.done closed exactly once; process() only
+// blocks on done if verification is still outstanding by the time it
+// gets there.
+type warpVerifier struct {
+	vm           VM
+	r            Rules
+	vdrState     validators.State
+	blockContext *smblock.Context
+
+	warpLock     *sync.Mutex
+	warpMessages map[ids.ID]*warpJob
+
+	jobs chan *warpJob
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newWarpVerifier creates a [warpVerifier] sharing warpMessages/warpLock
+// with the [Processor] it's attached to, and starts [workers] goroutines
+// draining its queue. blockContext may be nil (e.g. a block built
+// without one); any queued message then resolves unverified instead of
+// blocking forever, same as [BuildBlock] refusing to include one.
+func newWarpVerifier(vm VM, r Rules, blockContext *smblock.Context, workers int, warpLock *sync.Mutex, warpMessages map[ids.ID]*warpJob) *warpVerifier {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	wv := &warpVerifier{
+		vm:           vm,
+		r:            r,
+		vdrState:     vm.ValidatorState(),
+		blockContext: blockContext,
+		warpLock:     warpLock,
+		warpMessages: warpMessages,
+		jobs:         make(chan *warpJob, warpVerifyQueueSize),
+		stop:         make(chan struct{}),
+	}
+	wv.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go wv.worker()
+	}
+	return wv
+}
+
+// Queue registers every warp.Message attached to a tx in c and enqueues
+// it for verification. It is safe to call before [DefaultProcessor.process]
+// reaches c -- that's the point -- and safe to call concurrently with
+// other Queue calls.
+func (wv *warpVerifier) Queue(c *Chunk) {
+	wv.warpLock.Lock()
+	var jobs []*warpJob
+	for _, tx := range c.Txs {
+		if tx.WarpMessage == nil {
+			continue
+		}
+		job := &warpJob{
+			msg:     tx.WarpMessage,
+			done:    make(chan struct{}),
+			warpNum: len(wv.warpMessages),
+		}
+		wv.warpMessages[tx.ID()] = job
+		jobs = append(jobs, job)
+	}
+	wv.warpLock.Unlock()
+
+	for _, job := range jobs {
+		select {
+		case wv.jobs <- job:
+		case <-wv.stop:
+			return
+		}
+	}
+}
+
+// Stop abandons every outstanding verification and waits for workers to
+// exit. A job that never reaches a worker before Stop is called resolves
+// unverified (done is closed with verified left false) so no caller
+// blocked on it hangs forever. Safe to call multiple times.
+func (wv *warpVerifier) Stop() {
+	select {
+	case <-wv.stop:
+		// already stopped
+	default:
+		close(wv.stop)
+	}
+	wv.wg.Wait()
+
+	wv.warpLock.Lock()
+	for _, job := range wv.warpMessages {
+		select {
+		case <-job.done:
+		default:
+			close(job.done)
+		}
+	}
+	wv.warpLock.Unlock()
+}
+
+func (wv *warpVerifier) worker() {
+	defer wv.wg.Done()
+	for {
+		select {
+		case job, ok := <-wv.jobs:
+			if !ok {
+				return
+			}
+			wv.verify(job)
+		case <-wv.stop:
+			return
+		}
+	}
+}
+
+// verify resolves job, logging (but not propagating) any failure: an
+// unverified warp message isn't a hard error here, [DefaultProcessor.process]
+// still executes the tx, just without warp-gated actions able to act as
+// if the message were authentic. See [BuildBlock]'s identical handling.
+func (wv *warpVerifier) verify(job *warpJob) {
+	defer close(job.done)
+
+	msg := job.msg
+	allowed, num, denom := wv.r.GetWarpConfig(msg.SourceChainID)
+	if !allowed {
+		wv.vm.Logger().Warn("dropping warp message from disabled chain", zap.Stringer("sourceChainID", msg.SourceChainID))
+		return
+	}
+	if wv.blockContext == nil {
+		wv.vm.Logger().Warn("unable to verify warp message: no block context")
+		return
+	}
+
+	err := msg.Signature.Verify(
+		context.Background(), &msg.UnsignedMessage, wv.r.NetworkID(),
+		wv.vdrState, wv.blockContext.PChainHeight, num, denom,
+	)
+	if err != nil {
+		wv.vm.Logger().Warn("warp verification failed", zap.Error(err))
+		return
+	}
+	job.verified = true
+}
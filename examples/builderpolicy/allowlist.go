@@ -0,0 +1,77 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package builderpolicy shows how a subnet can plug a custom
+// [chain.BuilderPolicy] into BuildBlock without forking chain/builder.go.
+package builderpolicy
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/tstate"
+)
+
+// ReservedAllowList reserves a fraction of each block's unit budget for
+// transactions sponsored by an allow-listed address (e.g. a subnet's
+// governance multisig), so a busy retail mempool can't crowd them out.
+// Everything else -- fee ordering, the warp cap, the unit capacity
+// outside the reserve -- is still governed by BuildBlock itself; this policy
+// only vetoes non-allow-listed txs once the reserve would otherwise be
+// consumed.
+type ReservedAllowList struct {
+	allowed       map[codec.Address]bool
+	nonAllowedCap uint64 // total capacity minus the reserve
+
+	mu   sync.Mutex
+	used uint64 // units consumed this build attempt by non-allow-listed txs
+}
+
+// NewReservedAllowList reserves reserveFrac (e.g. 0.1 for 10%) of
+// maxUnits' total capacity for txs sponsored by an address in allowed;
+// everyone else is capped at the remaining 1-reserveFrac.
+func NewReservedAllowList(allowed []codec.Address, maxUnits chain.Dimensions, reserveFrac float64) *ReservedAllowList {
+	m := make(map[codec.Address]bool, len(allowed))
+	for _, a := range allowed {
+		m[a] = true
+	}
+	var total uint64
+	for _, u := range maxUnits {
+		total += u
+	}
+	reserved := uint64(float64(total) * reserveFrac)
+	return &ReservedAllowList{
+		allowed:       m,
+		nonAllowedCap: total - reserved,
+	}
+}
+
+func (p *ReservedAllowList) ShouldConsider(_ context.Context, tx *chain.Transaction, _ *tstate.TStateView) (include bool, restore bool, err error) {
+	if p.allowed[tx.Auth.Sponsor()] {
+		return true, true, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.used >= p.nonAllowedCap {
+		// Non-allow-listed txs have claimed everything outside the
+		// reserve; leave the rest for allow-listed sponsors.
+		return false, true, nil
+	}
+	return true, true, nil
+}
+
+func (p *ReservedAllowList) OnIncluded(_ context.Context, tx *chain.Transaction, result *chain.Result) {
+	if p.allowed[tx.Auth.Sponsor()] {
+		return
+	}
+	p.mu.Lock()
+	p.used += result.Units
+	p.mu.Unlock()
+}
+
+func (*ReservedAllowList) OnRejected(context.Context, *chain.Transaction, error) {}
+
+var _ chain.BuilderPolicy = (*ReservedAllowList)(nil)
@@ -22,6 +22,11 @@ import (
 type JSONRPCClient struct {
 	requester *requester.EndpointRequester
 
+	// uri is the server's base URI (no path suffix), kept around so the
+	// Subscribe* methods can derive the websocket pub/sub endpoint
+	// without re-deriving it from the JSON-RPC endpoint's path.
+	uri string
+
 	chainID ids.ID
 	g       *genesis.Genesis
 }
@@ -29,9 +34,8 @@ type JSONRPCClient struct {
 // New creates a new client object.
 func NewJSONRPCClient(uri string, chainID ids.ID) *JSONRPCClient {
 	uri = strings.TrimSuffix(uri, "/")
-	uri += JSONRPCEndpoint
-	req := requester.New(uri, consts.Name)
-	return &JSONRPCClient{req, chainID, nil}
+	req := requester.New(uri+JSONRPCEndpoint, consts.Name)
+	return &JSONRPCClient{requester: req, uri: uri, chainID: chainID}
 }
 
 func (cli *JSONRPCClient) Genesis(ctx context.Context) (*genesis.Genesis, error) {
@@ -122,6 +126,75 @@ func (cli *JSONRPCClient) WaitForTransaction(ctx context.Context, txID ids.ID) (
 	return success, nil
 }
 
+// DebugTraceTxArgs requests a replay trace of TxID, using the slot of the
+// chunk it was included in as the replay timestamp.
+type DebugTraceTxArgs struct {
+	TxID ids.ID `json:"txID"`
+
+	// ProgramCalls requests the WASM host-call trace for the tx's action,
+	// if it ran one; see chain.TraceConfig.
+	ProgramCalls bool `json:"programCalls"`
+}
+
+// DebugTraceTxReply carries the chain.ActionTrace for a DebugTraceTx
+// request.
+type DebugTraceTxReply struct {
+	Trace *chain.ActionTrace `json:"trace"`
+}
+
+// DebugTraceTx replays tx against the node's current accepted state, at
+// the slot of the chunk it was included in as timestamp, and returns a
+// structured trace: gas consumed, state keys read/written, and (with
+// programCalls set) the WASM host-call trace captured from
+// x/programs/host. The state as it stood at that slot is not
+// reconstructed.
+func (cli *JSONRPCClient) DebugTraceTx(ctx context.Context, txID ids.ID, programCalls bool) (*chain.ActionTrace, error) {
+	resp := new(DebugTraceTxReply)
+	err := cli.requester.SendRequest(
+		ctx,
+		"debugTraceTx",
+		&DebugTraceTxArgs{TxID: txID, ProgramCalls: programCalls},
+		resp,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Trace, nil
+}
+
+// DebugTraceChunkArgs requests a replay trace of every tx in ChunkID.
+type DebugTraceChunkArgs struct {
+	ChunkID ids.ID `json:"chunkID"`
+
+	// ProgramCalls requests the WASM host-call trace for every action
+	// that ran one; see chain.TraceConfig.
+	ProgramCalls bool `json:"programCalls"`
+}
+
+// DebugTraceChunkReply carries one chain.ActionTrace per tx in the
+// chunk, in the chunk's tx order.
+type DebugTraceChunkReply struct {
+	Traces []*chain.ActionTrace `json:"traces"`
+}
+
+// DebugTraceChunk replays every tx in chunkID, in order, against the
+// node's current accepted state with the chunk's slot as timestamp, and
+// returns one structured trace per tx. The state as it stood at that
+// slot is not reconstructed.
+func (cli *JSONRPCClient) DebugTraceChunk(ctx context.Context, chunkID ids.ID, programCalls bool) ([]*chain.ActionTrace, error) {
+	resp := new(DebugTraceChunkReply)
+	err := cli.requester.SendRequest(
+		ctx,
+		"debugTraceChunk",
+		&DebugTraceChunkArgs{ChunkID: chunkID, ProgramCalls: programCalls},
+		resp,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Traces, nil
+}
+
 var _ chain.Parser = (*Parser)(nil)
 
 type Parser struct {
@@ -0,0 +1,98 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package rpc
+
+import (
+	"net/http"
+
+	"github.com/ava-labs/avalanchego/ids"
+
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/state"
+)
+
+// DebugVM is the subset of the VM the `debug` namespace needs: mapping a
+// txID back to the chunk it landed in, and the already-accepted state to
+// replay against. Satisfied by *vm.VM, which registers DebugService
+// alongside its existing JSONRPCServer under the same HTTP handler.
+type DebugVM interface {
+	GetChunk(chunkID ids.ID) (*chain.Chunk, error)
+	GetTxChunk(txID ids.ID) (ids.ID, error)
+	ImmutableState() (state.Immutable, error)
+	StateManager() chain.StateManager
+	Rules(t int64) chain.Rules
+	FeeManager() (*chain.FeeManager, error)
+}
+
+// DebugService implements the `debug` JSON-RPC namespace: replaying
+// already-accepted txs/chunks for post-hoc trace analysis, without
+// re-signing or committing state. Both handlers share the same replay
+// path, chain.ReplayTx/chain.ReplayChunk, so the per-action trace shape
+// (gas consumed, state keys read/written, WASM host-call trace) is
+// identical whether it's reached via a single tx or a whole chunk.
+type DebugService struct {
+	vm DebugVM
+}
+
+func NewDebugService(vm DebugVM) *DebugService {
+	return &DebugService{vm: vm}
+}
+
+func (d *DebugService) DebugTraceTx(req *http.Request, args *DebugTraceTxArgs, reply *DebugTraceTxReply) error {
+	ctx := req.Context()
+
+	chunkID, err := d.vm.GetTxChunk(args.TxID)
+	if err != nil {
+		return err
+	}
+	c, err := d.vm.GetChunk(chunkID)
+	if err != nil {
+		return err
+	}
+	var tx *chain.Transaction
+	for _, t := range c.Txs {
+		if t.ID() == args.TxID {
+			tx = t
+			break
+		}
+	}
+	if tx == nil {
+		return ErrTxNotFound
+	}
+
+	im, err := d.vm.ImmutableState()
+	if err != nil {
+		return err
+	}
+	feeManager, err := d.vm.FeeManager()
+	if err != nil {
+		return err
+	}
+
+	cfg := chain.TraceConfig{ProgramCalls: args.ProgramCalls}
+	reply.Trace = chain.ReplayTx(ctx, cfg, tx, feeManager, d.vm.StateManager(), d.vm.Rules(c.Slot), im, c.Slot)
+	return nil
+}
+
+func (d *DebugService) DebugTraceChunk(req *http.Request, args *DebugTraceChunkArgs, reply *DebugTraceChunkReply) error {
+	ctx := req.Context()
+
+	c, err := d.vm.GetChunk(args.ChunkID)
+	if err != nil {
+		return err
+	}
+
+	im, err := d.vm.ImmutableState()
+	if err != nil {
+		return err
+	}
+	feeManager, err := d.vm.FeeManager()
+	if err != nil {
+		return err
+	}
+
+	cfg := chain.TraceConfig{ProgramCalls: args.ProgramCalls}
+	reply.Traces = chain.ReplayChunk(ctx, cfg, c, feeManager, d.vm.StateManager(), d.vm.Rules(c.Slot), im)
+	return nil
+}
@@ -0,0 +1,161 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/ava-labs/hypersdk/chain"
+)
+
+// subscribeBuffer bounds how many undelivered messages a subscription
+// channel holds. A message that arrives while the channel is full is
+// dropped rather than let an unbounded channel pile up; the connection
+// stays open.
+const subscribeBuffer = 256
+
+// reconnectBackoff is how long a subscription waits after a dropped
+// connection before redialing.
+const reconnectBackoff = time.Second
+
+// AcceptedTxFilter narrows SubscribeAcceptedTxs to txs matching every
+// set field; the zero value (aside from ActionType, see below) matches
+// every accepted tx.
+type AcceptedTxFilter struct {
+	// Author, if non-empty, matches only txs sponsored by this
+	// (bech32-formatted) address.
+	Author string `json:"author,omitempty"`
+
+	// ActionType, if >= 0, matches only txs whose action is registered
+	// under this ActionRegistry type ID. -1 (the zero value callers
+	// should set explicitly) matches any action.
+	ActionType int `json:"actionType"`
+
+	// StateKeys, if non-empty, matches txs that touch at least one of
+	// these keys, as returned by Transaction.StateKeys.
+	StateKeys []string `json:"stateKeys,omitempty"`
+}
+
+func (f *AcceptedTxFilter) matches(tx *chain.Transaction, sm chain.StateManager) bool {
+	if f.Author != "" && tx.Auth.Sponsor().String() != f.Author {
+		return false
+	}
+	if f.ActionType >= 0 && tx.Action.GetTypeID() != uint8(f.ActionType) {
+		return false
+	}
+	if len(f.StateKeys) > 0 {
+		keys, err := tx.StateKeys(sm)
+		if err != nil {
+			return false
+		}
+		found := false
+		for _, k := range f.StateKeys {
+			if _, ok := keys[k]; ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// subscribe dials endpoint (with query) and decodes every inbound text
+// frame as a T, pushing it onto the returned channel. The connection is
+// redialed with reconnectBackoff on any read/dial error until ctx is
+// done, so a subscriber doesn't have to notice or handle a transient
+// disconnect itself. The returned cancel func stops the subscription
+// and closes the channel.
+func subscribe[T any](ctx context.Context, wsURL string) (<-chan *T, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	ch := make(chan *T, subscribeBuffer)
+
+	go func() {
+		defer close(ch)
+		for {
+			if ctx.Err() != nil {
+				return
+			}
+			conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+			if err != nil {
+				select {
+				case <-time.After(reconnectBackoff):
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			for {
+				_, raw, err := conn.ReadMessage()
+				if err != nil {
+					break
+				}
+				var v T
+				if err := json.Unmarshal(raw, &v); err != nil {
+					continue
+				}
+				select {
+				case ch <- &v:
+				case <-ctx.Done():
+					conn.Close()
+					return
+				default:
+					// Consumer fell behind by subscribeBuffer messages;
+					// drop it rather than unboundedly queue.
+				}
+			}
+			conn.Close()
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case <-time.After(reconnectBackoff):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, cancel
+}
+
+func (cli *JSONRPCClient) wsURL(path, param, value string) string {
+	uri := strings.Replace(cli.uri, "http", "ws", 1)
+	if value == "" {
+		return uri + path
+	}
+	return uri + path + "?" + param + "=" + url.QueryEscape(value)
+}
+
+// SubscribeNewChunks streams every FilteredChunk as it's accepted,
+// without polling.
+func (cli *JSONRPCClient) SubscribeNewChunks(ctx context.Context) (<-chan *chain.FilteredChunk, context.CancelFunc) {
+	return subscribe[chain.FilteredChunk](ctx, cli.wsURL(NewChunksEndpoint, "", ""))
+}
+
+// SubscribeAcceptedTxs streams every accepted tx matching filter,
+// replacing a WaitForTransaction busy-wait loop with a push feed.
+func (cli *JSONRPCClient) SubscribeAcceptedTxs(ctx context.Context, filter AcceptedTxFilter) (<-chan *AcceptedTxEvent, context.CancelFunc, error) {
+	raw, err := json.Marshal(filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	ch, cancel := subscribe[AcceptedTxEvent](ctx, cli.wsURL(AcceptedTxsEndpoint, "filter", string(raw)))
+	return ch, cancel, nil
+}
+
+// SubscribeAccountEvents streams every program-emitted chain.Event
+// addressed to addr (a bech32-formatted address).
+func (cli *JSONRPCClient) SubscribeAccountEvents(ctx context.Context, addr string) (<-chan *chain.Event, context.CancelFunc) {
+	return subscribe[chain.Event](ctx, cli.wsURL(AccountEventsEndpoint, "address", addr))
+}
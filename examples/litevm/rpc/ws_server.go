@@ -0,0 +1,234 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/ava-labs/hypersdk/chain"
+)
+
+// NewChunksEndpoint, AcceptedTxsEndpoint, and AccountEventsEndpoint are
+// the websocket paths ChunkSubscriptionServer registers alongside
+// JSONRPCEndpoint, mirroring the pub/sub namespaces other Avalanche/
+// Ethereum clients expose next to their request/response RPC.
+const (
+	NewChunksEndpoint     = "/ws/chunks"
+	AcceptedTxsEndpoint   = "/ws/txs"
+	AccountEventsEndpoint = "/ws/events"
+)
+
+// subscriberSendBuffer bounds how many undelivered messages a
+// subscriber's outbound queue holds before it's considered too slow to
+// keep up and disconnected -- see deliver.
+const subscriberSendBuffer = 256
+
+// subscriber is one live websocket connection backing a SubscribeNewChunks/
+// SubscribeAcceptedTxs/SubscribeAccountEvents client call. send is drained by
+// writeLoop, which owns writing to conn; OnFilteredChunks only ever does a
+// non-blocking enqueue onto it.
+type subscriber struct {
+	conn *websocket.Conn
+	send chan []byte
+}
+
+func (sub *subscriber) writeLoop() {
+	defer sub.conn.Close()
+	for msg := range sub.send {
+		if err := sub.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+			return
+		}
+	}
+}
+
+// deliver enqueues msg for sub without blocking. A full buffer means sub
+// is already too slow to keep up with the acceptor, so it's disconnected
+// rather than allowed to apply backpressure to chunk processing.
+func deliver(sub *subscriber, msg []byte) {
+	select {
+	case sub.send <- msg:
+	default:
+		sub.conn.Close()
+	}
+}
+
+// AcceptedTxEvent is what SubscribeAcceptedTxs delivers for each tx
+// matching its filter: the tx itself, the height of the chunk it landed
+// in, and the events its action emitted.
+type AcceptedTxEvent struct {
+	Tx     *chain.Transaction `json:"tx"`
+	Height uint64             `json:"height"`
+	Events []chain.Event      `json:"events"`
+}
+
+// ChunkSubscriptionServer hosts the websocket pub/sub endpoints backing
+// JSONRPCClient's Subscribe* methods. It's wired into
+// chain.Config.OnFilteredChunks so every FilteredChunk the engine
+// produces is fanned out to matching subscribers directly from the
+// chunk-acceptance path, instead of subscribers busy-waiting on Tx() the
+// way WaitForTransaction does.
+type ChunkSubscriptionServer struct {
+	upgrader websocket.Upgrader
+	sm       chain.StateManager
+
+	mu          sync.RWMutex
+	newChunks   map[*subscriber]struct{}
+	acceptedTxs map[*subscriber]AcceptedTxFilter
+	accountSubs map[string]map[*subscriber]struct{}
+}
+
+func NewChunkSubscriptionServer(sm chain.StateManager) *ChunkSubscriptionServer {
+	return &ChunkSubscriptionServer{
+		sm:          sm,
+		newChunks:   map[*subscriber]struct{}{},
+		acceptedTxs: map[*subscriber]AcceptedTxFilter{},
+		accountSubs: map[string]map[*subscriber]struct{}{},
+	}
+}
+
+func (s *ChunkSubscriptionServer) upgrade(w http.ResponseWriter, r *http.Request) (*subscriber, error) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	sub := &subscriber{conn: conn, send: make(chan []byte, subscriberSendBuffer)}
+	go sub.writeLoop()
+	return sub, nil
+}
+
+// run blocks discarding inbound frames (these are send-only
+// subscriptions) until the connection closes, so the websocket
+// library's ping/pong and close handling keeps working.
+func (s *ChunkSubscriptionServer) run(sub *subscriber) {
+	defer close(sub.send)
+	for {
+		if _, _, err := sub.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *ChunkSubscriptionServer) ServeNewChunks(w http.ResponseWriter, r *http.Request) {
+	sub, err := s.upgrade(w, r)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.newChunks[sub] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.newChunks, sub)
+		s.mu.Unlock()
+	}()
+	s.run(sub)
+}
+
+func (s *ChunkSubscriptionServer) ServeAcceptedTxs(w http.ResponseWriter, r *http.Request) {
+	var filter AcceptedTxFilter
+	if raw := r.URL.Query().Get("filter"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &filter); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		filter = AcceptedTxFilter{ActionType: -1}
+	}
+
+	sub, err := s.upgrade(w, r)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.acceptedTxs[sub] = filter
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.acceptedTxs, sub)
+		s.mu.Unlock()
+	}()
+	s.run(sub)
+}
+
+func (s *ChunkSubscriptionServer) ServeAccountEvents(w http.ResponseWriter, r *http.Request) {
+	addr := r.URL.Query().Get("address")
+	if addr == "" {
+		http.Error(w, "missing address", http.StatusBadRequest)
+		return
+	}
+
+	sub, err := s.upgrade(w, r)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	if s.accountSubs[addr] == nil {
+		s.accountSubs[addr] = map[*subscriber]struct{}{}
+	}
+	s.accountSubs[addr][sub] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.accountSubs[addr], sub)
+		if len(s.accountSubs[addr]) == 0 {
+			delete(s.accountSubs, addr)
+		}
+		s.mu.Unlock()
+	}()
+	s.run(sub)
+}
+
+// OnFilteredChunks is a chain.Config.OnFilteredChunks callback: it fans
+// the chunks the engine just produced for height out to every matching
+// subscriber. Delivery is best-effort per subscriber (see deliver), so
+// this never blocks the caller regardless of how many -- or how slow --
+// subscribers are attached.
+func (s *ChunkSubscriptionServer) OnFilteredChunks(height uint64, chunks []*chain.FilteredChunk) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, c := range chunks {
+		if len(s.newChunks) > 0 {
+			if msg, err := json.Marshal(c); err == nil {
+				for sub := range s.newChunks {
+					deliver(sub, msg)
+				}
+			}
+		}
+
+		for i, tx := range c.Txs {
+			events := c.Events[i]
+
+			for sub, filter := range s.acceptedTxs {
+				if !filter.matches(tx, s.sm) {
+					continue
+				}
+				msg, err := json.Marshal(&AcceptedTxEvent{Tx: tx, Height: height, Events: events})
+				if err != nil {
+					continue
+				}
+				deliver(sub, msg)
+			}
+
+			for _, event := range events {
+				event := event
+				subs := s.accountSubs[event.Address.String()]
+				if len(subs) == 0 {
+					continue
+				}
+				msg, err := json.Marshal(&event)
+				if err != nil {
+					continue
+				}
+				for sub := range subs {
+					deliver(sub, msg)
+				}
+			}
+		}
+	}
+}
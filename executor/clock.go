@@ -0,0 +1,153 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package executor
+
+import (
+	"sync"
+	"time"
+)
+
+// Timer is the subset of *time.Timer's behavior a Clock needs to expose,
+// so a fake Clock can hand out a fake Timer instead of a concrete
+// *time.Timer it has no way to fabricate.
+type Timer interface {
+	// C returns the channel a single value is sent on when the timer
+	// fires.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing, if it hasn't already. It
+	// reports whether the stop was what prevented the fire.
+	Stop() bool
+}
+
+// Clock abstracts the passage of time for the executor, so tests can
+// swap in a FakeClock that only moves forward when told to, instead of
+// depending on real wall-clock delays to force an ordering.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	Sleep(d time.Duration)
+}
+
+// realClock is the default Clock, backed by the time package.
+type realClock struct{}
+
+// NewRealClock returns the Clock executor.New uses unless overridden
+// with WithClock.
+func NewRealClock() Clock { return realClock{} }
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (r realTimer) C() <-chan time.Time { return r.t.C }
+func (r realTimer) Stop() bool          { return r.t.Stop() }
+
+// FakeClock is a logical Clock for tests: Now only changes when Advance
+// is called, and every outstanding Timer/Sleep fires based on that
+// logical time rather than anything measured against a real clock.
+type FakeClock struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	now  time.Time
+
+	// timers is every outstanding fakeTimer -- created via NewTimer or
+	// Sleep, and not yet fired or Stopped.
+	timers []*fakeTimer
+}
+
+// NewFakeClock returns a FakeClock set to the Unix epoch.
+func NewFakeClock() *FakeClock {
+	c := &FakeClock{now: time.Unix(0, 0)}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the clock's current logical time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer returns a Timer that fires once Advance moves the clock to or
+// past d after the current logical time.
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, fireAt: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+	return t
+}
+
+// Sleep blocks the calling goroutine until Advance moves the clock to or
+// past d after the current logical time.
+func (c *FakeClock) Sleep(d time.Duration) {
+	<-c.NewTimer(d).C()
+}
+
+// BlockUntil blocks until at least n Timer/Sleep calls are currently
+// outstanding against this clock. It gives a test a race-free way to
+// know a goroutine under test has actually reached its Sleep/NewTimer
+// call -- and is now parked waiting on the fake clock -- before the test
+// calls Advance to release it.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+// Advance moves the clock's logical time forward by d, firing (and
+// removing) every outstanding timer whose deadline is now at or before
+// the new time.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+
+	remaining := c.timers[:0]
+	for _, t := range c.timers {
+		if t.fireAt.After(c.now) {
+			remaining = append(remaining, t)
+			continue
+		}
+		select {
+		case t.c <- c.now:
+		default:
+		}
+	}
+	c.timers = remaining
+}
+
+type fakeTimer struct {
+	clock  *FakeClock
+	fireAt time.Time
+	c      chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+// Stop removes t from its clock's outstanding timers, if it's still
+// there (it won't be if it already fired).
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, ft := range t.clock.timers {
+		if ft == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrStopped is returned by Wait once Stop has been called, for
+	// whichever tasks were still pending when it took effect.
+	ErrStopped = errors.New("executor stopped")
+	// ErrAlreadyStarted is returned by Start when the current
+	// generation's workers have already been launched. New and Reset
+	// both call Start themselves, and Stop and Wait do not clear this,
+	// so a direct Start call after any of them returns this error.
+	ErrAlreadyStarted = errors.New("executor already started")
+)
+
+// TaskError pairs a task's index with the error it returned. Wait
+// reports one of these per failure under WithErrorPolicy(ContinueOnError),
+// instead of abandoning the run at the first one.
+type TaskError struct {
+	TaskID int
+	Err    error
+}
+
+func (e TaskError) Error() string {
+	return fmt.Sprintf("task %d: %v", e.TaskID, e.Err)
+}
+
+// Unwrap lets errors.Is/errors.As see through to Err.
+func (e TaskError) Unwrap() error { return e.Err }
+
+// Errors aggregates every task that failed during a
+// WithErrorPolicy(ContinueOnError) run, in the order they were recorded.
+// It implements Unwrap() []error, the form errors.Is/errors.As follow
+// through a joined error (the same shape errors.Join produces).
+type Errors []TaskError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, te := range e {
+		msgs[i] = te.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e Errors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, te := range e {
+		errs[i] = te
+	}
+	return errs
+}
@@ -0,0 +1,129 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package executor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/hypersdk/state"
+)
+
+// EventType identifies what happened to a task over its lifetime. Events
+// fire in this order for a task that runs to completion:
+// TaskQueued, (optionally) TaskBlocked, TaskStarted, and exactly one of
+// TaskCompleted/TaskFailed.
+type EventType int
+
+const (
+	// TaskQueued fires when Run adds a task to the executor, before its
+	// dependencies are known to have resolved.
+	TaskQueued EventType = iota
+	// TaskBlocked fires in addition to, and chronologically after,
+	// TaskQueued when the task has at least one unresolved
+	// dependency; Event.Keys is the subset of the task's declared keys
+	// that caused it to block.
+	TaskBlocked
+	// TaskStarted fires when a worker dequeues the task and is about to
+	// call its function.
+	TaskStarted
+	// TaskCompleted fires when the task's function returns nil.
+	// Event.Duration is how long it ran for.
+	TaskCompleted
+	// TaskFailed fires when the task's function returns a non-nil
+	// error. Event.Err is that error.
+	TaskFailed
+)
+
+// String implements fmt.Stringer.
+func (t EventType) String() string {
+	switch t {
+	case TaskQueued:
+		return "TaskQueued"
+	case TaskBlocked:
+		return "TaskBlocked"
+	case TaskStarted:
+		return "TaskStarted"
+	case TaskCompleted:
+		return "TaskCompleted"
+	case TaskFailed:
+		return "TaskFailed"
+	default:
+		return "Unknown"
+	}
+}
+
+// Event describes a single task lifecycle transition. Which fields are
+// meaningful depends on Type: Keys is set for TaskQueued/TaskBlocked,
+// Duration for TaskCompleted, and Err for TaskFailed.
+type Event struct {
+	Type     EventType
+	TaskID   int
+	Keys     state.Keys
+	Duration time.Duration
+	Err      error
+}
+
+// CancelFunc unsubscribes a channel returned by Subscribe. It is
+// idempotent and safe to call more than once.
+type CancelFunc func()
+
+// Subscribe registers a new listener for this Executor's task lifecycle
+// events and returns a channel of buffer capacity to receive them, plus
+// a CancelFunc to stop listening. If the channel fills up (a slow
+// consumer), further events are dropped for that subscriber rather than
+// blocking the executor's hot path -- see Dropped. ctx, if non-nil,
+// unsubscribes automatically once it's Done.
+func (e *Executor) Subscribe(ctx context.Context, buffer int) (<-chan Event, CancelFunc) {
+	ch := make(chan Event, buffer)
+
+	e.subMu.Lock()
+	id := e.nextSubID
+	e.nextSubID++
+	e.subs[id] = ch
+	e.subMu.Unlock()
+
+	var cancelOnce sync.Once
+	cancel := func() {
+		cancelOnce.Do(func() {
+			e.subMu.Lock()
+			delete(e.subs, id)
+			e.subMu.Unlock()
+			close(ch)
+		})
+	}
+
+	if ctx != nil {
+		go func() {
+			<-ctx.Done()
+			cancel()
+		}()
+	}
+
+	return ch, cancel
+}
+
+// Dropped returns the total number of events dropped across every
+// subscriber so far, because that subscriber's channel was full when the
+// event fired.
+func (e *Executor) Dropped() int64 {
+	return e.eventsDropped.Load()
+}
+
+// emit delivers ev to every current subscriber without blocking: a
+// subscriber whose channel is full has ev dropped and Dropped's counter
+// incremented instead of stalling the caller (always a worker goroutine
+// or Run, both on the executor's hot path).
+func (e *Executor) emit(ev Event) {
+	e.subMu.Lock()
+	defer e.subMu.Unlock()
+	for _, ch := range e.subs {
+		select {
+		case ch <- ev:
+		default:
+			e.eventsDropped.Add(1)
+		}
+	}
+}
@@ -4,11 +4,10 @@
 package executor
 
 import (
+	"context"
 	"sync"
 	"sync/atomic"
-	"fmt"
-
-	// "github.com/ava-labs/avalanchego/utils/set"
+	"time"
 
 	"github.com/ava-labs/hypersdk/state"
 )
@@ -20,7 +19,12 @@ import (
 // are executed in the order they were queued.
 // Tasks with no conflicts are executed immediately.
 type Executor struct {
-	metrics    Metrics
+	metrics     Metrics
+	clock       Clock
+	errorPolicy ErrorPolicy
+	concurrency int
+	itemsHint   int
+
 	wg         sync.WaitGroup
 	executable chan *task
 
@@ -28,42 +32,185 @@ type Executor struct {
 	err      error
 	stopOnce sync.Once
 
+	errMu sync.Mutex
+	errs  []TaskError
+
+	// ctx is the parent of every task's context: canceling it (via Stop,
+	// the early-exit path in runWorker, or Reset tearing down a
+	// generation) cancels every task's context in turn, whether or not
+	// it has started running yet.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	quit     chan struct{}
+	quitOnce sync.Once
+
+	subMu         sync.Mutex
+	subs          map[int]chan Event
+	nextSubID     int
+	eventsDropped atomic.Int64
+
 	l         sync.Mutex
+	started   bool
 	done      bool
 	completed int
 	tasks     map[int]*task
 	nodes     map[string]*node
 }
 
+// node tracks the most recent writer of a state key along with every
+// reader that has run since that writer (or since the key was first
+// touched, if no writer has run yet). It is the RWLock for a single key:
+// a new Read only needs to wait on [lastWriter], while a new Write or
+// Allocate must wait on [lastWriter] and every task in [readers] (a
+// fan-in of all readers that raced ahead of it).
 type node struct {
-	id int 
-	isAllocateWrite bool
+	lastWriter int // id of last writer/allocate task to touch this key, -1 if none
+	readers    []int
+}
+
+// Option configures an Executor at construction time. See WithClock.
+type Option func(*Executor)
+
+// WithClock overrides the Clock an Executor uses to time tasks (see the
+// TaskCompleted event's Duration), instead of the real one New uses by
+// default. Tests use this to swap in a FakeClock.
+func WithClock(c Clock) Option {
+	return func(e *Executor) { e.clock = c }
+}
+
+// ErrorPolicy controls what an Executor does with its other tasks when
+// one of them fails, and what Wait then reports. See WithErrorPolicy.
+type ErrorPolicy int
+
+const (
+	// FailFast is the default: the first task to fail stops the
+	// executor the same way Stop does (see Stop), and Wait returns that
+	// one error. Every task still blocked behind a conflicting key at
+	// that point is abandoned.
+	FailFast ErrorPolicy = iota
+	// ContinueOnError lets every independent task run to completion
+	// even after one fails: a failing task still releases its locks so
+	// whatever is blocked behind it can proceed, and Wait returns an
+	// Errors aggregating every failure instead of just the first. This
+	// suits speculative execution, where one failing tx shouldn't abort
+	// the rest of the batch.
+	ContinueOnError
+)
+
+// WithErrorPolicy overrides how an Executor reacts to a failing task.
+// The default, if this option isn't given, is FailFast.
+func WithErrorPolicy(p ErrorPolicy) Option {
+	return func(e *Executor) { e.errorPolicy = p }
 }
 
-// New creates a new [Executor].
-func New(items, concurrency int, metrics Metrics) *Executor {
+// New creates a new [Executor] and starts it.
+func New(items, concurrency int, metrics Metrics, opts ...Option) *Executor {
 	e := &Executor{
-		metrics:    metrics,
-		stop:       make(chan struct{}),
-		tasks:      make(map[int]*task, items),
-		nodes:      make(map[string]*node, items*2), // TODO: tune this
-		executable: make(chan *task, items),       // ensure we don't block while holding lock
+		metrics:     metrics,
+		clock:       realClock{},
+		concurrency: concurrency,
+		itemsHint:   items,
+		subs:        make(map[int]chan Event),
 	}
-	e.wg.Add(concurrency)
-	for i := 0; i < concurrency; i++ {
-		go e.runWorker()
+	for _, opt := range opts {
+		opt(e)
 	}
+	e.resetState()
+	_ = e.Start() // cannot fail: a freshly constructed Executor is never already started
 	return e
 }
 
+// resetState (re)initializes every field Run/Wait/Stop mutate, sizing
+// the task/node maps and the executable channel from itemsHint the same
+// way New originally did. It leaves started false; the caller is
+// responsible for calling Start afterwards.
+func (e *Executor) resetState() {
+	e.stop = make(chan struct{})
+	e.quit = make(chan struct{})
+	e.quitOnce = sync.Once{}
+	e.stopOnce = sync.Once{}
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+	e.err = nil
+	e.errs = nil
+	e.started = false
+	e.done = false
+	e.completed = 0
+	e.tasks = make(map[int]*task, e.itemsHint)
+	e.nodes = make(map[string]*node, e.itemsHint*2) // TODO: tune this
+	e.executable = make(chan *task, e.itemsHint)    // ensure we don't block while holding lock
+}
+
+// Start launches this Executor's worker goroutines. New and Reset both
+// call Start themselves; a further call returns ErrAlreadyStarted.
+func (e *Executor) Start() error {
+	e.l.Lock()
+	defer e.l.Unlock()
+	if e.started {
+		return ErrAlreadyStarted
+	}
+	e.started = true
+	e.wg.Add(e.concurrency)
+	for i := 0; i < e.concurrency; i++ {
+		go e.runWorker()
+	}
+	return nil
+}
+
+// Reset drains whatever this Executor was in the middle of (stopping it
+// first if it hadn't already stopped, then waiting for every in-flight
+// task to return) and rebuilds it to the same reusable zero-state New
+// produces, including relaunching its worker goroutines. This lets a
+// caller reuse one Executor across many batches (e.g. one per block)
+// instead of constructing a new one each time.
+func (e *Executor) Reset() error {
+	_ = e.Stop()
+	e.drain()
+
+	e.l.Lock()
+	e.resetState()
+	e.l.Unlock()
+
+	return e.Start()
+}
+
+// Quit returns a channel that is closed once every worker goroutine from
+// the current Start has fully exited -- after Stop (directly, via Wait,
+// or via Reset) has drained whatever task was in flight. It is
+// re-created by Reset, so a channel returned before a Reset stays closed
+// (or never closes, if the caller never drained that generation) even
+// though a later generation's Quit is a new, open channel.
+func (e *Executor) Quit() <-chan struct{} {
+	e.l.Lock()
+	defer e.l.Unlock()
+	return e.quit
+}
+
+// drain blocks until every worker goroutine from the current generation
+// has exited, then closes quit exactly once for that generation. Wait
+// and Reset both funnel through it so Quit fires regardless of which one
+// a caller uses to tear an Executor down.
+func (e *Executor) drain() {
+	e.wg.Wait()
+	e.quitOnce.Do(func() { close(e.quit) })
+}
+
 type task struct {
 	id int
-	f  func() error
+	f  func(ctx context.Context) error
+
+	// ctx is canceled (via cancel) once this task no longer needs to
+	// run: the executor stopped, an earlier task errored, or -- if this
+	// task was registered via RunCtxWithDeadline -- its deadline
+	// elapsed, even if it's still blocked behind a conflicting key.
+	ctx    context.Context
+	cancel context.CancelFunc
 
 	dependencies atomic.Int64
 	blocking     map[int]*task
 
-	executed bool
+	executed  bool
+	startedAt time.Time
 }
 
 func (e *Executor) runWorker() {
@@ -75,39 +222,48 @@ func (e *Executor) runWorker() {
 			if !ok {
 				return
 			}
-			fmt.Printf("a tid %v\n", t.id)
-			if err := t.f(); err != nil {
-				e.stopOnce.Do(func() {
-					e.err = err
-					close(e.stop)
-				})
-				return
+			t.startedAt = e.clock.Now()
+			e.emit(Event{Type: TaskStarted, TaskID: t.id})
+			err := t.f(t.ctx)
+			t.cancel() // release t's deadline watcher, if it has one
+			if err != nil {
+				e.emit(Event{Type: TaskFailed, TaskID: t.id, Err: err})
+				if e.errorPolicy == FailFast {
+					e.stopOnce.Do(func() {
+						e.err = err
+						close(e.stop)
+						e.cancel()
+					})
+					return
+				}
+				// ContinueOnError: record the failure and fall through
+				// to release t's locks like a completed task, instead
+				// of abandoning whatever is blocked behind it.
+				e.errMu.Lock()
+				e.errs = append(e.errs, TaskError{TaskID: t.id, Err: err})
+				e.errMu.Unlock()
+			} else {
+				e.emit(Event{Type: TaskCompleted, TaskID: t.id, Duration: e.clock.Now().Sub(t.startedAt)})
 			}
 
-			fmt.Printf("b tid %v\n", t.id)
-			//fmt.Printf("tid %v | t.blocking %v\n", t.id, len(t.blocking))
 			e.l.Lock()
 			for _, bt := range t.blocking {
-				//fmt.Printf("tid %v | id %v | executed %v | dep %v | b %v\n", t.id, bt.id, bt.executed, bt.dependencies.Load(), bt.blocking)
 				if bt.dependencies.Load() > 0 && bt.dependencies.Add(-1) > 0 {
 					continue
 				}
 				if !bt.executed {
-					//fmt.Printf("len %v\n", len(e.executable))
-					e.executable <- bt	
+					e.executable <- bt
 				}
 			}
 			t.blocking = nil // free memory
 			t.executed = true
 			e.completed++
 			if e.done && e.completed == len(e.tasks) {
-				fmt.Printf("this sholdn't print\n")
 				// We will close here if there are unexecuted tasks
 				// when we call [Wait].
 				close(e.executable)
 			}
 			e.l.Unlock()
-			fmt.Printf("c tid %v\n", t.id)
 		case <-e.stop:
 			return
 		}
@@ -116,49 +272,125 @@ func (e *Executor) runWorker() {
 
 // Run executes [f] after all previously enqueued [f] with
 // overlapping [keys] are executed.
+//
+// A prefix declared via state.NewPrefix conflicts by its own Name: two
+// tasks that both touch the same prefix serialize against each other, the
+// same as if it were any other key in [keys]. It does not automatically
+// conflict with a concrete key nested under it -- a caller that needs a
+// prefix-scoped task to serialize against a task touching one specific
+// key under that prefix must declare the prefix itself (not just the
+// concrete key) for both.
+//
+// See RunCtx for a variant whose f can observe cancellation.
 func (e *Executor) Run(keys state.Keys, f func() error) {
+	e.run(keys, 0, func(context.Context) error { return f() })
+}
+
+// RunCtx is Run, but f additionally receives a context.Context that is
+// canceled once f no longer needs to keep running: Stop is called, the
+// executor is Reset, or -- under the default FailFast error policy only
+// -- an earlier task returns a non-nil error (the same early-exit
+// semantics Wait reports). Under WithErrorPolicy(ContinueOnError), an
+// earlier task's failure does not cancel ctx, since other tasks are
+// meant to keep running. f is responsible for checking ctx itself --
+// the executor cannot interrupt it, only tell it to stop.
+func (e *Executor) RunCtx(keys state.Keys, f func(ctx context.Context) error) {
+	e.run(keys, 0, f)
+}
+
+// RunCtxWithDeadline is RunCtx, but f's context is additionally canceled
+// once [d] elapses on the executor's Clock (see WithClock), timed from
+// this call rather than from whenever f actually starts running. This
+// lets a task give up on a conflicting key it has been blocked behind
+// for too long: by the time it is finally dispatched, ctx is already
+// done and f can return promptly instead of doing doomed work.
+func (e *Executor) RunCtxWithDeadline(keys state.Keys, d time.Duration, f func(ctx context.Context) error) {
+	e.run(keys, d, f)
+}
+
+// run is the shared implementation behind Run, RunCtx, and
+// RunCtxWithDeadline. deadline of 0 means no deadline.
+func (e *Executor) run(keys state.Keys, deadline time.Duration, f func(ctx context.Context) error) {
 	e.l.Lock()
 	defer e.l.Unlock()
 
+	ctx, cancel := context.WithCancel(e.ctx)
+	if deadline > 0 {
+		timer := e.clock.NewTimer(deadline)
+		go func() {
+			select {
+			case <-timer.C():
+				cancel()
+			case <-ctx.Done():
+				timer.Stop()
+			}
+		}()
+	}
+
 	// Add task to map
 	id := len(e.tasks)
 	t := &task{
 		id:       id,
 		f:        f,
+		ctx:      ctx,
+		cancel:   cancel,
 		blocking: map[int]*task{},
 	}
 	e.tasks[id] = t
+	e.emit(Event{Type: TaskQueued, TaskID: id, Keys: keys})
 
 	// Record dependencies
+	//
+	// A Read only needs to wait for the previous writer to finish (it can
+	// run concurrently with any other Reads that are also waiting on that
+	// writer). A Write/Allocate must wait for the previous writer AND every
+	// Read that has run since that writer, so we fan-in a dependency on
+	// each of them before resetting the reader set for the key.
+	//
+	// blockers dedupes by task ID across every key in [keys]: two tasks
+	// can share more than one key, and t must only depend on (and be
+	// added to) a given blocker's [task.blocking] once, not once per
+	// shared key -- otherwise the blocker's single decrement on finishing
+	// would leave t.dependencies stuck above zero forever.
+	blockingKeys := state.Keys{}
+	blockers := map[int]*task{}
 	for k, v := range keys {
 		n, ok := e.nodes[k]
-		if ok {
-			lt := e.tasks[n.id]
-			if !lt.executed {
-				switch {
-				case v == state.Read && !n.isAllocateWrite:
-					lt.blocking[id] = t
-					continue
-				/*case v == state.Read && n.isAllocateWrite:
-					t.dependencies.Add(int64(1))
-					lt.blocking[id] = t
-				case (v.Has(state.Allocate) || v.Has(state.Write)) && !n.isAllocateWrite:
-					// blocked by all reads
-					t.dependencies.Add(int64(len(lt.blocking)))
-					lt.blocking[id] = t
-				case (v.Has(state.Allocate) || v.Has(state.Write)) && n.isAllocateWrite:
-					t.dependencies.Add(int64(1))
-					lt.blocking[id] = t*/			
+		if !ok {
+			n = &node{lastWriter: -1}
+			e.nodes[k] = n
+		}
+		isWrite := v.Has(state.Allocate) || v.Has(state.Write)
+
+		if lw := n.lastWriter; lw >= 0 {
+			if lt := e.tasks[lw]; !lt.executed {
+				blockers[lw] = lt
+				blockingKeys.Add(k, v)
+			}
+		}
+		if isWrite {
+			for _, rid := range n.readers {
+				if rt := e.tasks[rid]; !rt.executed {
+					blockers[rid] = rt
+					blockingKeys.Add(k, v)
 				}
 			}
+			n.lastWriter = id
+			n.readers = n.readers[:0]
+		} else {
+			n.readers = append(n.readers, id)
 		}
-		e.nodes[k] = &node{id: id, isAllocateWrite: v.Has(state.Allocate) || v.Has(state.Write)}
+	}
+	for _, bt := range blockers {
+		t.dependencies.Add(1)
+		bt.blocking[id] = t
 	}
 
 	if t.dependencies.Load() > 0 {
 		if e.metrics != nil {
 			e.metrics.RecordBlocked()
 		}
+		e.emit(Event{Type: TaskBlocked, TaskID: id, Keys: blockingKeys})
 		return
 	}
 
@@ -169,15 +401,28 @@ func (e *Executor) Run(keys state.Keys, f func() error) {
 	}
 }
 
-func (e *Executor) Stop() {
+// Stop cancels every not-yet-executed task: workers blocked waiting for
+// the next one return as soon as they observe it, every task's context
+// (see RunCtx) is canceled whether or not it has started running yet,
+// and Wait returns ErrStopped once they've all drained. It is idempotent
+// -- only the first call has any effect -- and always returns nil; it
+// never fails.
+func (e *Executor) Stop() error {
 	e.stopOnce.Do(func() {
 		e.err = ErrStopped
 		close(e.stop)
+		e.cancel()
 	})
+	return nil
 }
 
 // Wait returns as soon as all enqueued [f] are executed.
 //
+// Under the default FailFast error policy, this returns the first task's
+// error (or ErrStopped, if Stop triggered it) and nil otherwise, exactly
+// as it always has. Under WithErrorPolicy(ContinueOnError), this instead
+// returns an Errors aggregating every failed task, or nil if none failed.
+//
 // You should not call [Run] after [Wait] is called.
 func (e *Executor) Wait() error {
 	e.l.Lock()
@@ -188,6 +433,18 @@ func (e *Executor) Wait() error {
 		close(e.executable)
 	}
 	e.l.Unlock()
-	e.wg.Wait()
-	return e.err
+	e.drain()
+
+	if e.err != nil {
+		// A FailFast failure or an explicit Stop always wins: Stop's
+		// godoc promises ErrStopped regardless of error policy.
+		return e.err
+	}
+	e.errMu.Lock()
+	errs := e.errs
+	e.errMu.Unlock()
+	if len(errs) > 0 {
+		return Errors(errs)
+	}
+	return nil
 }
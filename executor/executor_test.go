@@ -4,11 +4,11 @@
 package executor
 
 import (
-	_ "errors"
+	"context"
+	"errors"
 	"sync"
 	"testing"
 	"time"
-	"fmt"
 
 	"github.com/ava-labs/avalanchego/ids"
 	"github.com/stretchr/testify/require"
@@ -16,7 +16,28 @@ import (
 	"github.com/ava-labs/hypersdk/state"
 )
 
-/*func TestExecutorNoConflicts(t *testing.T) {
+// testMetrics records how many tasks were immediately executable versus
+// blocked behind a conflicting key when enqueued, so tests can assert on
+// the concurrency the executor actually achieved.
+type testMetrics struct {
+	l          sync.Mutex
+	blocked    int
+	executable int
+}
+
+func (m *testMetrics) RecordBlocked() {
+	m.l.Lock()
+	defer m.l.Unlock()
+	m.blocked++
+}
+
+func (m *testMetrics) RecordExecutable() {
+	m.l.Lock()
+	defer m.l.Unlock()
+	m.executable++
+}
+
+func TestExecutorNoConflicts(t *testing.T) {
 	var (
 		require   = require.New(t)
 		l         sync.Mutex
@@ -79,7 +100,8 @@ func TestExecutorSimpleConflict(t *testing.T) {
 		conflictKey = ids.GenerateTestID().String()
 		l           sync.Mutex
 		completed   = make([]int, 0, 100)
-		e           = New(100, 4, nil)
+		clk         = NewFakeClock()
+		e           = New(100, 4, nil, WithClock(clk))
 	)
 	for i := 0; i < 100; i++ {
 		s := make(state.Keys, (i + 1))
@@ -92,7 +114,7 @@ func TestExecutorSimpleConflict(t *testing.T) {
 		ti := i
 		e.Run(s, func() error {
 			if ti == 0 {
-				time.Sleep(3 * time.Second)
+				clk.Sleep(3 * time.Second)
 			}
 
 			l.Lock()
@@ -101,6 +123,18 @@ func TestExecutorSimpleConflict(t *testing.T) {
 			return nil
 		})
 	}
+	// Task 0 is the only one parked on the fake clock -- every other
+	// conflictKey writer (10, 20, ..., 90) is already blocked behind it
+	// in the dependency graph, clock or no clock.
+	clk.BlockUntil(1)
+	// Let the 90 non-conflicting tasks finish before releasing task 0, so
+	// none of them can complete after a task of the conflict chain.
+	require.Eventually(func() bool {
+		l.Lock()
+		defer l.Unlock()
+		return len(completed) == 90
+	}, 10*time.Second, time.Millisecond)
+	clk.Advance(3 * time.Second)
 	require.NoError(e.Wait())
 	require.Equal([]int{0, 10, 20, 30, 40, 50, 60, 70, 80, 90}, completed[90:])
 }
@@ -144,6 +178,45 @@ func TestExecutorMultiConflict(t *testing.T) {
 	require.Equal([]int{0, 10, 15, 20, 30, 40, 50, 60, 70, 80, 90}, completed[89:])
 }
 
+// TestExecutorSharedMultiKeyConflict checks that two tasks sharing more
+// than one key create exactly one dependency between them. Recording
+// one dependency per shared key instead of one per blocking task would
+// leave the successor's dependency counter stuck above zero forever,
+// since the blocker only sends one decrement per entry in its blocking
+// map.
+func TestExecutorSharedMultiKeyConflict(t *testing.T) {
+	var (
+		require   = require.New(t)
+		keyA      = ids.GenerateTestID().String()
+		keyB      = ids.GenerateTestID().String()
+		l         sync.Mutex
+		completed = make([]int, 0, 2)
+		e         = New(2, 2, nil)
+	)
+	s0 := make(state.Keys, 2)
+	s0.Add(keyA, state.Write)
+	s0.Add(keyB, state.Write)
+	e.Run(s0, func() error {
+		l.Lock()
+		completed = append(completed, 0)
+		l.Unlock()
+		return nil
+	})
+
+	s1 := make(state.Keys, 2)
+	s1.Add(keyA, state.Write)
+	s1.Add(keyB, state.Write)
+	e.Run(s1, func() error {
+		l.Lock()
+		completed = append(completed, 1)
+		l.Unlock()
+		return nil
+	})
+
+	require.NoError(e.Wait())
+	require.Equal([]int{0, 1}, completed)
+}
+
 func TestEarlyExit(t *testing.T) {
 	var (
 		require   = require.New(t)
@@ -170,6 +243,60 @@ func TestEarlyExit(t *testing.T) {
 	}
 	require.Less(len(completed), 500)
 	require.ErrorIs(e.Wait(), terr) // no task running
+
+	// Quit fires once Wait has drained every worker.
+	select {
+	case <-e.Quit():
+	default:
+		t.Fatal("Quit channel not closed after Wait")
+	}
+
+	// Reset re-enables Run after the prior early exit.
+	require.NoError(e.Reset())
+	done := make(chan struct{})
+	e.Run(state.Keys{}, func() error {
+		close(done)
+		return nil
+	})
+	<-done
+	require.NoError(e.Wait())
+}
+
+// TestEarlyExitContinueOnError is TestEarlyExit's counterpart under
+// WithErrorPolicy(ContinueOnError): unlike FailFast, a failing task
+// doesn't stop the executor, so every task still runs to completion and
+// Wait reports the one failure by index instead of abandoning the rest.
+func TestEarlyExitContinueOnError(t *testing.T) {
+	var (
+		require   = require.New(t)
+		l         sync.Mutex
+		completed = make([]int, 0, 500)
+		e         = New(500, 4, nil, WithErrorPolicy(ContinueOnError))
+		terr      = errors.New("uh oh")
+	)
+	for i := 0; i < 500; i++ {
+		s := make(state.Keys, (i + 1))
+		for k := 0; k < i+1; k++ {
+			s.Add(ids.GenerateTestID().String(), state.Read|state.Write)
+		}
+		ti := i
+		e.Run(s, func() error {
+			l.Lock()
+			completed = append(completed, ti)
+			l.Unlock()
+			if ti == 200 {
+				return terr
+			}
+			return nil
+		})
+	}
+
+	var errs Errors
+	require.ErrorAs(e.Wait(), &errs)
+	require.Len(completed, 500) // unlike FailFast, nothing is abandoned
+	require.Len(errs, 1)
+	require.Equal(200, errs[0].TaskID)
+	require.ErrorIs(errs[0].Err, terr)
 }
 
 func TestStop(t *testing.T) {
@@ -197,6 +324,11 @@ func TestStop(t *testing.T) {
 	}
 	require.Less(len(completed), 500)
 	require.ErrorIs(e.Wait(), ErrStopped) // no task running
+
+	// Quit fires exactly once: it's already closed, and reading it again
+	// doesn't block or panic.
+	<-e.Quit()
+	<-e.Quit()
 }
 
 // W->W->W->...
@@ -207,7 +339,8 @@ func TestManyWrites(t *testing.T) {
 		l           sync.Mutex
 		completed   = make([]int, 0, 100)
 		answer      = make([]int, 0, 100)
-		e           = New(100, 4, nil)
+		clk         = NewFakeClock()
+		e           = New(100, 4, nil, WithClock(clk))
 	)
 	for i := 0; i < 100; i++ {
 		answer = append(answer, i)
@@ -219,7 +352,7 @@ func TestManyWrites(t *testing.T) {
 		ti := i
 		e.Run(s, func() error {
 			if ti == 0 {
-				time.Sleep(3 * time.Second)
+				clk.Sleep(3 * time.Second)
 			}
 
 			l.Lock()
@@ -228,6 +361,8 @@ func TestManyWrites(t *testing.T) {
 			return nil
 		})
 	}
+	clk.BlockUntil(1)
+	clk.Advance(3 * time.Second)
 	require.NoError(e.Wait())
 	require.Equal(answer, completed)
 }
@@ -261,7 +396,7 @@ func TestManyReads(t *testing.T) {
 	require.NoError(e.Wait())
 	// 0..99 are ran in parallel, so non-deterministic
 	require.Len(completed, 100)
-}*/
+}
 
 // W->R->R->...
 func TestWriteThenRead(t *testing.T) {
@@ -270,7 +405,8 @@ func TestWriteThenRead(t *testing.T) {
 		conflictKey = ids.GenerateTestID().String()
 		l           sync.Mutex
 		completed   = make([]int, 0, 100)
-		e           = New(100, 4, nil)
+		clk         = NewFakeClock()
+		e           = New(100, 4, nil, WithClock(clk))
 	)
 	for i := 0; i < 100; i++ {
 		s := make(state.Keys, (i + 1))
@@ -285,7 +421,7 @@ func TestWriteThenRead(t *testing.T) {
 		ti := i
 		e.Run(s, func() error {
 			if ti == 0 {
-				time.Sleep(1 * time.Second)
+				clk.Sleep(1 * time.Second)
 			}
 
 			l.Lock()
@@ -294,9 +430,299 @@ func TestWriteThenRead(t *testing.T) {
 			return nil
 		})
 	}
+	// The 99 readers all depend on task 0 (the writer) regardless of the
+	// clock; task 0 is the only one actually parked on it.
+	clk.BlockUntil(1)
+	clk.Advance(1 * time.Second)
 	require.NoError(e.Wait())
-	fmt.Printf("completed %v\n", completed)
 	require.Equal(0, completed[0]) // Write first to execute
 	// 1..99 are ran in parallel, so non-deterministic
 	require.Len(completed, 100)
 }
+
+// R->R->R->W (many parallel reads, then a write that must fan-in on all of them)
+func TestManyReadsThenWrite(t *testing.T) {
+	var (
+		require     = require.New(t)
+		conflictKey = ids.GenerateTestID().String()
+		l           sync.Mutex
+		completed   = make([]int, 0, 101)
+		metrics     = &testMetrics{}
+		e           = New(101, 8, metrics)
+	)
+	for i := 0; i < 100; i++ {
+		s := make(state.Keys, 1)
+		s.Add(conflictKey, state.Read)
+		ti := i
+		e.Run(s, func() error {
+			time.Sleep(50 * time.Millisecond)
+			l.Lock()
+			completed = append(completed, ti)
+			l.Unlock()
+			return nil
+		})
+	}
+	s := make(state.Keys, 1)
+	s.Add(conflictKey, state.Write)
+	e.Run(s, func() error {
+		l.Lock()
+		completed = append(completed, 100)
+		l.Unlock()
+		return nil
+	})
+	require.NoError(e.Wait())
+	require.Len(completed, 101)
+	require.Equal(100, completed[100]) // write must be last, after every read
+	require.Zero(metrics.blocked)      // reads never conflict with each other
+}
+
+// W->R, R->R (a writer followed by several readers that can run concurrently
+// with each other once the writer is done)
+func TestWriteAfterRead(t *testing.T) {
+	var (
+		require     = require.New(t)
+		conflictKey = ids.GenerateTestID().String()
+		l           sync.Mutex
+		completed   = make([]int, 0, 11)
+		e           = New(11, 8, nil)
+	)
+	s := make(state.Keys, 1)
+	s.Add(conflictKey, state.Write)
+	e.Run(s, func() error {
+		time.Sleep(100 * time.Millisecond)
+		l.Lock()
+		completed = append(completed, 0)
+		l.Unlock()
+		return nil
+	})
+	for i := 1; i <= 10; i++ {
+		s := make(state.Keys, 1)
+		s.Add(conflictKey, state.Read)
+		ti := i
+		e.Run(s, func() error {
+			l.Lock()
+			completed = append(completed, ti)
+			l.Unlock()
+			return nil
+		})
+	}
+	require.NoError(e.Wait())
+	require.Len(completed, 11)
+	require.Equal(0, completed[0]) // writer must execute before any reader
+}
+
+// R->W, W->W (a read then a conflicting write, then a second write that must
+// wait on the first write)
+func TestReadThenWrite(t *testing.T) {
+	var (
+		require     = require.New(t)
+		conflictKey = ids.GenerateTestID().String()
+		l           sync.Mutex
+		completed   = make([]int, 0, 3)
+		e           = New(3, 4, nil)
+	)
+	s := make(state.Keys, 1)
+	s.Add(conflictKey, state.Read)
+	e.Run(s, func() error {
+		time.Sleep(100 * time.Millisecond)
+		l.Lock()
+		completed = append(completed, 0)
+		l.Unlock()
+		return nil
+	})
+	for i := 1; i <= 2; i++ {
+		s := make(state.Keys, 1)
+		s.Add(conflictKey, state.Write)
+		ti := i
+		e.Run(s, func() error {
+			l.Lock()
+			completed = append(completed, ti)
+			l.Unlock()
+			return nil
+		})
+	}
+	require.NoError(e.Wait())
+	require.Equal([]int{0, 1, 2}, completed)
+}
+
+// W->W->W->... (every write must wait for the one before it)
+func TestWriteAfterWrite(t *testing.T) {
+	var (
+		require     = require.New(t)
+		conflictKey = ids.GenerateTestID().String()
+		l           sync.Mutex
+		completed   = make([]int, 0, 50)
+		e           = New(50, 8, nil)
+	)
+	for i := 0; i < 50; i++ {
+		s := make(state.Keys, 1)
+		s.Add(conflictKey, state.Write)
+		ti := i
+		e.Run(s, func() error {
+			l.Lock()
+			completed = append(completed, ti)
+			l.Unlock()
+			return nil
+		})
+	}
+	require.NoError(e.Wait())
+	answer := make([]int, 50)
+	for i := range answer {
+		answer[i] = i
+	}
+	require.Equal(answer, completed)
+}
+
+// TestSubscribeEvents checks that a subscriber observes the expected
+// lifecycle for a task with no conflicts (Queued, Started, Completed)
+// and for one that blocks behind a conflicting key (Queued, Blocked,
+// Started, Completed).
+func TestSubscribeEvents(t *testing.T) {
+	require := require.New(t)
+	conflictKey := ids.GenerateTestID().String()
+	e := New(2, 1, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, unsubscribe := e.Subscribe(ctx, 16)
+	defer unsubscribe()
+
+	release0 := make(chan struct{})
+	s0 := make(state.Keys, 1)
+	s0.Add(conflictKey, state.Write)
+	e.Run(s0, func() error {
+		<-release0
+		return nil
+	})
+	require.Equal(TaskQueued, (<-events).Type)
+	// Reading TaskStarted confirms the (only) worker dequeued task 0 and
+	// is now blocked in its function on release0, so task 1 below is
+	// guaranteed to observe task 0 as not yet executed.
+	require.Equal(TaskStarted, (<-events).Type)
+
+	s1 := make(state.Keys, 1)
+	s1.Add(conflictKey, state.Write)
+	e.Run(s1, func() error { return nil })
+	require.Equal(TaskQueued, (<-events).Type)
+	require.Equal(TaskBlocked, (<-events).Type)
+
+	close(release0)
+	require.NoError(e.Wait())
+
+	// Task 0 completing is what unblocks and dispatches task 1.
+	require.Equal(TaskCompleted, (<-events).Type)
+	require.Equal(TaskStarted, (<-events).Type)
+	require.Equal(TaskCompleted, (<-events).Type)
+}
+
+// TestSubscribeDropsSlowConsumer checks that a subscriber whose channel
+// fills up has events dropped (and counted) instead of blocking the
+// executor.
+func TestSubscribeDropsSlowConsumer(t *testing.T) {
+	require := require.New(t)
+	e := New(50, 4, nil)
+
+	events, unsubscribe := e.Subscribe(context.Background(), 1)
+	defer unsubscribe()
+
+	for i := 0; i < 50; i++ {
+		e.Run(state.Keys{}, func() error { return nil })
+	}
+	require.NoError(e.Wait())
+
+	require.Positive(e.Dropped())
+	// Draining the small buffer doesn't panic or block.
+	for {
+		select {
+		case <-events:
+		default:
+			return
+		}
+	}
+}
+
+// TestStopCancelsInFlightTask checks that Stop cancels the context of a
+// task that is already running, not just ones still queued.
+func TestStopCancelsInFlightTask(t *testing.T) {
+	require := require.New(t)
+	e := New(1, 1, nil)
+
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	e.RunCtx(state.Keys{}, func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		close(canceled)
+		return ctx.Err()
+	})
+
+	<-started
+	require.NoError(e.Stop())
+	<-canceled
+	require.ErrorIs(e.Wait(), ErrStopped)
+}
+
+// TestErrorCancelsConcurrentTask checks that a task erroring out cancels
+// the context of another task that is already executing concurrently,
+// not just ones enqueued afterwards.
+func TestErrorCancelsConcurrentTask(t *testing.T) {
+	require := require.New(t)
+	e := New(2, 2, nil)
+	terr := errors.New("uh oh")
+
+	otherStarted := make(chan struct{})
+	otherCanceled := make(chan struct{})
+	e.RunCtx(state.Keys{}, func(ctx context.Context) error { // task 0
+		<-otherStarted
+		return terr
+	})
+	e.RunCtx(state.Keys{}, func(ctx context.Context) error { // task 1
+		close(otherStarted)
+		<-ctx.Done()
+		close(otherCanceled)
+		return nil
+	})
+
+	<-otherCanceled
+	require.ErrorIs(e.Wait(), terr)
+}
+
+// TestRunCtxWithDeadlineCancelsBlockedTask checks that a deadline set via
+// RunCtxWithDeadline can elapse while the task is still blocked behind a
+// conflicting key -- before it has ever run -- so it sees ctx already
+// done the moment it's finally dispatched.
+func TestRunCtxWithDeadlineCancelsBlockedTask(t *testing.T) {
+	require := require.New(t)
+	conflictKey := ids.GenerateTestID().String()
+	clk := NewFakeClock()
+	e := New(2, 2, nil, WithClock(clk))
+
+	s0 := make(state.Keys, 1)
+	s0.Add(conflictKey, state.Write)
+	writerRelease := make(chan struct{})
+	e.Run(s0, func() error {
+		<-writerRelease
+		return nil
+	})
+
+	s1 := make(state.Keys, 1)
+	s1.Add(conflictKey, state.Write)
+	var taskErr error
+	done := make(chan struct{})
+	e.RunCtxWithDeadline(s1, time.Second, func(ctx context.Context) error {
+		<-ctx.Done()
+		taskErr = ctx.Err()
+		close(done)
+		return ctx.Err()
+	})
+
+	// Task 1 is still blocked behind the writer -- its deadline timer is
+	// the only thing parked on the fake clock.
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+
+	close(writerRelease)
+	<-done
+	require.ErrorIs(e.Wait(), context.Canceled)
+	require.ErrorIs(taskErr, context.Canceled)
+}
@@ -0,0 +1,50 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package keys implements the chunk-suffix encoding hypersdk state keys
+// use to declare, up front, the most storage a value at that key will
+// ever occupy. Charging fees off that declaration (rather than the
+// actual value size) lets PreExecute price a write before the value
+// itself has been computed.
+package keys
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// chunkSize is the number of value bytes a single chunk accounts for.
+const chunkSize = 64
+
+// maxChunks is the largest chunk count a key may declare or a value may
+// require; it is what fits in the suffix's uint16.
+const maxChunks = math.MaxUint16
+
+// EncodeChunks appends a big-endian uint16 suffix to key recording
+// maxChunks, the most chunks a value stored at key will ever need. The
+// result is the actual state key used on the write path.
+func EncodeChunks(key []byte, maxChunks uint16) []byte {
+	encoded := make([]byte, len(key)+2)
+	copy(encoded, key)
+	binary.BigEndian.PutUint16(encoded[len(key):], maxChunks)
+	return encoded
+}
+
+// MaxChunks decodes the suffix added by EncodeChunks, returning false if
+// key is too short to contain one.
+func MaxChunks(key []byte) (uint16, bool) {
+	if len(key) < 2 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint16(key[len(key)-2:]), true
+}
+
+// NumChunks returns the number of chunkSize-byte chunks needed to store
+// value, or false if that exceeds maxChunks.
+func NumChunks(value []byte) (uint16, bool) {
+	chunks := (len(value) + chunkSize - 1) / chunkSize
+	if chunks > maxChunks {
+		return 0, false
+	}
+	return uint16(chunks), true
+}
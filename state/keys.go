@@ -0,0 +1,132 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package state
+
+import (
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/database"
+)
+
+// Permissions is a bitmask describing what a transaction (or other state
+// consumer) declared it may do to a given key. tstate.TStateView checks
+// these before allowing GetValue/Insert/Remove/Iterator to touch a key,
+// so a bad declaration fails closed rather than silently under-charging
+// fees or racing the executor's conflict detection.
+type Permissions byte
+
+const None Permissions = 0
+
+// Read, Allocate, Write, and Scan are registered with defaultRegistry
+// below rather than declared as plain const bits, so NewKey/NewPrefix
+// can validate a declaration against every bit actually in use --
+// including ones a VM registers later with RegisterPermission -- instead
+// of a fixed compile-time mask.
+var (
+	// Read permits GetValue and Has.
+	Read = RegisterPermission("Read", 1<<0)
+	// Allocate permits Insert to create a key that doesn't yet resolve
+	// to a value anywhere (the parent TState, this view's storage, or
+	// this view's own pending changes). It is checked in addition to,
+	// not instead of, TStateView's runtime EnableAllocation/DisableAllocation
+	// toggle -- both must allow it.
+	Allocate = RegisterPermission("Allocate", 1<<1)
+	// Write permits Insert on a key that already resolves to a value,
+	// and Remove.
+	Write = RegisterPermission("Write", 1<<2)
+	// Scan permits keys under a NewPrefix declaration to be visited by
+	// Iterator and ReverseIterator and checked with Has. On an exact key
+	// it permits Has only; iterators never return a key outside every
+	// declared prefix.
+	Scan = RegisterPermission("Scan", 1<<3)
+)
+
+// HasPermission reports whether p grants every bit set in require. It
+// returns false if require itself contains any bit outside what
+// defaultRegistry has registered, regardless of what p is set to -- this
+// keeps a stray or out-of-range bit from silently granting access.
+func (p Permissions) HasPermission(require Permissions) bool {
+	if !defaultRegistry.Valid(require) {
+		return false
+	}
+	return p&require == require
+}
+
+// Has is an alias for HasPermission used by callers (e.g. executor)
+// that track permissions as a plain bitmask rather than through a Key.
+func (p Permissions) Has(require Permissions) bool {
+	return p.HasPermission(require)
+}
+
+// Key is a single scope declaration: either an exact key name a tx may
+// act on (see NewKey), or a namespace prefix it may act on every key
+// under (see NewPrefix).
+type Key struct {
+	Name       string
+	Permission Permissions
+	// IsPrefix is true for keys created by NewPrefix. TStateView treats
+	// Name as a prefix match rather than an exact key in that case, and
+	// consults Permission for whichever bits the caller declared --
+	// Scan to range-scan the namespace, plus Read/Allocate/Write to
+	// GetValue/Insert/Remove keys under it without enumerating them.
+	IsPrefix bool
+}
+
+// NewKey declares exact access to name with the union of perms. Each
+// perm must be a bit registered with defaultRegistry (Read/Allocate/
+// Write/Scan, or a bit a VM registered itself via RegisterPermission);
+// NewKey panics otherwise, since an unregistered bit is a construction-
+// time mistake rather than a declaration that should silently grant
+// nothing.
+func NewKey(name string, perms ...Permissions) Key {
+	p := union(perms)
+	if !defaultRegistry.Valid(p) {
+		panic(fmt.Sprintf("state: NewKey(%q): permission %#02x contains unregistered bits", name, byte(p)))
+	}
+	return Key{Name: name, Permission: p}
+}
+
+// NewPrefix declares perms over every key with prefix as a prefix,
+// plus Scan so the namespace can be range-scanned. Use this instead of
+// NewKey when a tx needs to act on a dynamic set of keys under a
+// namespace -- an iterator, a sweep, an admin operation -- without
+// enumerating them up front.
+//
+// Where two declared prefixes both cover a key, TStateView.checkScope
+// resolves the conflict by longest-prefix-match: whichever covering
+// prefix is most specific decides, and it must carry the required
+// permission bits itself -- a shorter prefix's permission is never used
+// to fill in what a more specific, but less-permissive, prefix lacks.
+func NewPrefix(prefix string, perms ...Permissions) Key {
+	p := union(perms) | Scan
+	if !defaultRegistry.Valid(p) {
+		panic(fmt.Sprintf("state: NewPrefix(%q): permission %#02x contains unregistered bits", prefix, byte(p)))
+	}
+	return Key{Name: prefix, Permission: p, IsPrefix: true}
+}
+
+func union(perms []Permissions) Permissions {
+	var p Permissions
+	for _, perm := range perms {
+		p |= perm
+	}
+	return p
+}
+
+// Keys is the set of state keys (and their required permissions) a
+// transaction declares before execution. It is keyed by the raw key
+// string rather than [Key] because callers (e.g. the executor) only
+// ever need to merge permissions for a given name, not distinguish
+// prefix declarations.
+type Keys map[string]Permissions
+
+// Add merges perm into the existing permissions for name, if any.
+func (k Keys) Add(name string, perm Permissions) {
+	k[name] = k[name] | perm
+}
+
+// Iterator walks a range of state keys in order. It is re-exported from
+// avalanchego/database so callers building against state.Iterator don't
+// need an additional import for the concrete type.
+type Iterator = database.Iterator
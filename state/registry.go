@@ -0,0 +1,89 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package state
+
+import (
+	"fmt"
+	"sync"
+)
+
+// PermissionRegistry tracks which Permissions bits are in use and under
+// what name. The package's own Read/Allocate/Write/Scan are registered
+// against defaultRegistry below; a VM that needs an additional gating
+// bit (e.g. "Iterate" or "Admin") registers it the same way and gets a
+// typed Permissions constant back, rather than picking an arbitrary
+// integer that NewKey/NewPrefix have no way to validate.
+type PermissionRegistry struct {
+	mu     sync.Mutex
+	byName map[string]Permissions
+	byBit  map[Permissions]string
+	all    Permissions
+}
+
+// NewPermissionRegistry returns an empty registry.
+func NewPermissionRegistry() *PermissionRegistry {
+	return &PermissionRegistry{
+		byName: make(map[string]Permissions),
+		byBit:  make(map[Permissions]string),
+	}
+}
+
+// Register declares name as bit's permanent name and returns bit for
+// convenience at the call site. bit must be a single set bit not
+// already registered under a different name, and name must not already
+// be registered -- both are wiring bugs a VM should catch at startup, so
+// Register panics rather than returning an error.
+func (r *PermissionRegistry) Register(name string, bit Permissions) Permissions {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if bit == 0 || bit&(bit-1) != 0 {
+		panic(fmt.Sprintf("state: permission %q must be exactly one bit, got %#02x", name, byte(bit)))
+	}
+	if existing, ok := r.byBit[bit]; ok {
+		panic(fmt.Sprintf("state: permission bit %#02x already registered as %q", byte(bit), existing))
+	}
+	if _, ok := r.byName[name]; ok {
+		panic(fmt.Sprintf("state: permission %q already registered", name))
+	}
+
+	r.byName[name] = bit
+	r.byBit[bit] = name
+	r.all |= bit
+	return bit
+}
+
+// Lookup returns the bit registered under name, if any.
+func (r *PermissionRegistry) Lookup(name string) (Permissions, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.byName[name]
+	return p, ok
+}
+
+// Valid reports whether every bit set in p was registered with r.
+func (r *PermissionRegistry) Valid(p Permissions) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return p&^r.all == 0
+}
+
+// defaultRegistry owns every bit this package defines. NewKey and
+// NewPrefix validate against it, so a VM that wants its own gating bits
+// registers them here via RegisterPermission before declaring keys with
+// them.
+var defaultRegistry = NewPermissionRegistry()
+
+// RegisterPermission registers name/bit with the package's default
+// registry -- the one NewKey and NewPrefix validate against. It panics
+// under the same conditions as (*PermissionRegistry).Register.
+func RegisterPermission(name string, bit Permissions) Permissions {
+	return defaultRegistry.Register(name, bit)
+}
+
+// DefaultRegistry returns the registry NewKey and NewPrefix validate
+// against.
+func DefaultRegistry() *PermissionRegistry {
+	return defaultRegistry
+}
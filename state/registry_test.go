@@ -0,0 +1,48 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package state_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ava-labs/hypersdk/state"
+)
+
+// TestPermissionRegistryCustomBit checks that a VM can register its own
+// permission bit and immediately use it in NewKey, just like a built-in
+// one.
+func TestPermissionRegistryCustomBit(t *testing.T) {
+	require := require.New(t)
+
+	admin := state.RegisterPermission("TestPermissionRegistryCustomBit/Admin", 1<<6)
+	key := state.NewKey("root", state.Read, admin)
+	require.True(key.Permission.HasPermission(admin))
+	require.True(key.Permission.HasPermission(state.Read))
+}
+
+// TestPermissionRegistryRejectsCollision checks that registering the
+// same bit, or the same name, twice panics rather than silently
+// overwriting the earlier registration.
+func TestPermissionRegistryRejectsCollision(t *testing.T) {
+	require := require.New(t)
+
+	r := state.NewPermissionRegistry()
+	r.Register("Read", 1<<0)
+
+	require.Panics(func() { r.Register("AlsoBitZero", 1<<0) })
+	require.Panics(func() { r.Register("Read", 1<<1) })
+	require.Panics(func() { r.Register("NotASingleBit", 1<<0|1<<1) })
+}
+
+// TestNewKeyRejectsUnregisteredBit checks that NewKey/NewPrefix panic on
+// a permission bit the registry backing them doesn't know about, instead
+// of producing a key with no effective permissions.
+func TestNewKeyRejectsUnregisteredBit(t *testing.T) {
+	require := require.New(t)
+
+	require.Panics(func() { state.NewKey("k", state.Permissions(1<<7)) })
+	require.Panics(func() { state.NewPrefix("p", state.Permissions(1<<7)) })
+}
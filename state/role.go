@@ -0,0 +1,56 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package state
+
+import "fmt"
+
+// Role is a named, reusable bundle of permission bits -- e.g.
+//
+//	TransferRole = state.NewRole(state.Read, state.Write)
+//
+// declared once and reused across every key an action touches, instead
+// of repeating the same state.Read, state.Write pair at every NewKey
+// call site. Its zero value is the empty role (no permissions).
+type Role struct {
+	allow Permissions
+	deny  Permissions
+}
+
+// NewRole returns a Role granting the union of perms. Each perm must be
+// registered with defaultRegistry, under the same rule as NewKey --
+// NewRole panics otherwise.
+func NewRole(perms ...Permissions) Role {
+	p := union(perms)
+	if !defaultRegistry.Valid(p) {
+		panic(fmt.Sprintf("state: NewRole: permission %#02x contains unregistered bits", byte(p)))
+	}
+	return Role{allow: p}
+}
+
+// Union returns a Role granting everything either r or other grants,
+// with both roles' deny-lists still applied.
+func (r Role) Union(other Role) Role {
+	return Role{allow: r.allow | other.allow, deny: r.deny | other.deny}
+}
+
+// Deny returns a Role like r, but with perms withheld even if r's
+// underlying grant (or a later Union) would otherwise include them. This
+// mirrors how a revoked capability overrides role membership in
+// role-based KV systems: the overlay is checked after composition, not
+// baked into allow, so it survives a Union on either side.
+func (r Role) Deny(perms ...Permissions) Role {
+	return Role{allow: r.allow, deny: r.deny | union(perms)}
+}
+
+// Permission returns the effective Permissions this role grants: every
+// allowed bit not also denied.
+func (r Role) Permission() Permissions {
+	return r.allow &^ r.deny
+}
+
+// NewKeyWithRole declares exact access to name with role's effective
+// permissions. It is equivalent to NewKey(name, role.Permission()).
+func NewKeyWithRole(name string, role Role) Key {
+	return NewKey(name, role.Permission())
+}
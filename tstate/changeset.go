@@ -0,0 +1,107 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package tstate
+
+import (
+	"sort"
+
+	"github.com/ava-labs/avalanchego/utils/maybe"
+
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/consts"
+)
+
+// ChangeSet is a TStateView's pending changes, bundled so a parent TState
+// can absorb them directly (TState.ApplyChangeSet) instead of replaying
+// the view's op log, or so they can be shipped to another node that
+// already has the same base state (Marshal/UnmarshalChangeSet) instead of
+// that node re-executing whatever produced them.
+type ChangeSet struct {
+	// Values is the net value each changed key should now resolve to
+	// (maybe.Nothing for a deletion).
+	Values map[string]maybe.Maybe[[]byte]
+
+	// Allocates and Writes mirror TStateView.KeyOperations: the chunks
+	// declared by each newly-allocated key, and 1 (0 for a deletion) for
+	// every key with a net write. Carried along so a recipient can charge
+	// the same fees the originating node did without recomputing them
+	// from Values alone.
+	Allocates map[string]uint16
+	Writes    map[string]uint16
+}
+
+// ChangeSet returns this view's pending changes as a ChangeSet, ready to
+// hand to a parent TState's ApplyChangeSet or to marshal for another node.
+func (tsv *TStateView) ChangeSet() ChangeSet {
+	return ChangeSet{
+		Values:    tsv.pendingChangedKeys,
+		Allocates: tsv.allocates,
+		Writes:    tsv.writes,
+	}
+}
+
+// ApplyChangeSet merges cs into ts directly: the same end state as
+// committing the TStateView cs was taken from, without needing that
+// view's op log.
+func (ts *TState) ApplyChangeSet(cs ChangeSet) {
+	for k, v := range cs.Values {
+		ts.changedKeys[k] = v
+		ts.versions[k]++
+	}
+}
+
+// Marshal encodes cs as a compact diff, keys written in sorted order so
+// that two equal ChangeSets always encode identically -- suitable for
+// shipping block-execution results to another node.
+func (cs ChangeSet) Marshal(p *codec.Packer) {
+	ks := make([]string, 0, len(cs.Values))
+	for k := range cs.Values {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+
+	p.PackInt(len(ks))
+	for _, k := range ks {
+		p.PackBytes([]byte(k))
+		v := cs.Values[k]
+		p.PackBool(!v.IsNothing())
+		if !v.IsNothing() {
+			p.PackBytes(v.Value())
+		}
+		p.PackUint64(uint64(cs.Allocates[k]))
+		p.PackUint64(uint64(cs.Writes[k]))
+	}
+}
+
+// UnmarshalChangeSet decodes a ChangeSet written by ChangeSet.Marshal.
+func UnmarshalChangeSet(p *codec.Packer) (ChangeSet, error) {
+	cs := ChangeSet{
+		Values:    map[string]maybe.Maybe[[]byte]{},
+		Allocates: map[string]uint16{},
+		Writes:    map[string]uint16{},
+	}
+
+	count := p.UnpackInt(false)
+	for i := 0; i < count; i++ {
+		var k []byte
+		p.UnpackBytes(consts.MaxInt, true, &k)
+
+		hasValue := p.UnpackBool()
+		if hasValue {
+			var v []byte
+			p.UnpackBytes(consts.MaxInt, false, &v)
+			cs.Values[string(k)] = maybe.Some(v)
+		} else {
+			cs.Values[string(k)] = maybe.Nothing[[]byte]()
+		}
+
+		// Marshal writes 0 for a key with no allocation; a real allocation
+		// always declares at least one chunk, so 0 means "no entry".
+		if allocates := uint16(p.UnpackUint64(false)); allocates != 0 {
+			cs.Allocates[string(k)] = allocates
+		}
+		cs.Writes[string(k)] = uint16(p.UnpackUint64(false))
+	}
+	return cs, p.Err()
+}
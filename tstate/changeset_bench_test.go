@@ -0,0 +1,72 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package tstate
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/utils/maybe"
+
+	"github.com/ava-labs/hypersdk/keys"
+)
+
+// applyOpsReplay is the naive way to get a view's changes into dst: walk
+// its op log and re-derive the net value for each key, one op at a time,
+// the way a commit path without ChangeSet would have to. It exists only
+// to give BenchmarkCommitOpsReplay something to measure against
+// BenchmarkCommitChangeSet.
+func applyOpsReplay(dst map[string]maybe.Maybe[[]byte], tsv *TStateView) {
+	for _, o := range tsv.ops {
+		if v, ok := tsv.pendingChangedKeys[o.k]; ok {
+			dst[o.k] = v
+		}
+	}
+}
+
+// benchKeys returns n distinct, chunk-suffixed keys for benchmark use.
+func benchKeys(n int) [][]byte {
+	out := make([][]byte, n)
+	for i := range out {
+		out[i] = keys.EncodeChunks([]byte(fmt.Sprintf("benchkey-%d", i)), 1)
+	}
+	return out
+}
+
+// insertedView returns an unscoped TStateView with n keys freshly
+// inserted, ready for a commit-path benchmark to apply.
+func insertedView(ctx context.Context, ks [][]byte) *TStateView {
+	ts := New(len(ks))
+	tsv := ts.NewConcurrentView(map[string][]byte{})
+	for _, k := range ks {
+		_ = tsv.Insert(ctx, k, testVal)
+	}
+	return tsv
+}
+
+func BenchmarkCommitOpsReplay(b *testing.B) {
+	ctx := context.Background()
+	ks := benchKeys(10_000)
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		tsv := insertedView(ctx, ks)
+		dst := make(map[string]maybe.Maybe[[]byte], len(ks))
+		b.StartTimer()
+
+		applyOpsReplay(dst, tsv)
+	}
+}
+
+func BenchmarkCommitChangeSet(b *testing.B) {
+	ctx := context.Background()
+	ks := benchKeys(10_000)
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		tsv := insertedView(ctx, ks)
+		b.StartTimer()
+
+		tsv.ts.ApplyChangeSet(tsv.ChangeSet())
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package tstate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/utils/maybe"
+	"github.com/ava-labs/avalanchego/utils/set"
+
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/consts"
+	"github.com/ava-labs/hypersdk/state"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestChangeSetApply checks that applying a view's ChangeSet to a fresh
+// TState produces the same committed state as Commit would have.
+func TestChangeSetApply(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	ts := New(10)
+	tsv := ts.NewView(set.Of(state.NewKey(key2str, state.Read, state.Write, state.Allocate)), map[string][]byte{})
+	require.NoError(tsv.Insert(ctx, key2, testVal))
+	cs := tsv.ChangeSet()
+
+	other := New(10)
+	other.ApplyChangeSet(cs)
+
+	require.Equal(maybe.Some(testVal), other.changedKeys[key2str])
+	require.EqualValues(1, other.versions[key2str])
+}
+
+// TestChangeSetMarshal checks that a ChangeSet round-trips through
+// Marshal/UnmarshalChangeSet unchanged.
+func TestChangeSetMarshal(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	ts := New(10)
+	keySet := set.Of(
+		state.NewKey(key1str, state.Read, state.Write),
+		state.NewKey(key2str, state.Read, state.Write, state.Allocate),
+	)
+	tsv := ts.NewView(keySet, map[string][]byte{key1str: testVal})
+	require.NoError(tsv.Remove(ctx, key1))
+	require.NoError(tsv.Insert(ctx, key2, testVal))
+	cs := tsv.ChangeSet()
+
+	p := codec.NewWriter(consts.MaxInt)
+	cs.Marshal(p)
+	require.NoError(p.Err())
+
+	r := codec.NewReader(p.Bytes(), consts.MaxInt)
+	decoded, err := UnmarshalChangeSet(r)
+	require.NoError(err)
+
+	require.Equal(cs.Values, decoded.Values)
+	require.Equal(cs.Allocates, decoded.Allocates)
+	require.Equal(cs.Writes, decoded.Writes)
+}
@@ -0,0 +1,182 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package tstate
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/ava-labs/avalanchego/database"
+
+	"github.com/ava-labs/hypersdk/state"
+)
+
+// Has reports whether key currently resolves to a value. Unlike
+// GetValue, it is satisfied by either an exact state.Read/state.Scan
+// declaration on key or a state.NewPrefix covering it with state.Scan.
+func (tsv *TStateView) Has(_ context.Context, key []byte) (bool, error) {
+	k := string(key)
+	if !tsv.canScan(k) {
+		return false, ErrInvalidKeyOrPermission
+	}
+	tsv.markRead(k)
+	_, err := tsv.getValue(k)
+	if errors.Is(err, database.ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Iterator returns an ascending iterator over every key in [start, end)
+// (a nil bound is open-ended) that falls under a state.NewPrefix this
+// view declared with state.Scan. Keys outside every declared prefix are
+// never returned, even if they fall in [start, end).
+func (tsv *TStateView) Iterator(_ context.Context, start, end []byte) (state.Iterator, error) {
+	rangeKeys, err := tsv.scannableKeys(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return &viewIterator{tsv: tsv, keys: rangeKeys, idx: -1}, nil
+}
+
+// ReverseIterator is Iterator in descending order.
+func (tsv *TStateView) ReverseIterator(_ context.Context, start, end []byte) (state.Iterator, error) {
+	rangeKeys, err := tsv.scannableKeys(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return &viewIterator{tsv: tsv, keys: rangeKeys, reverse: true, idx: len(rangeKeys)}, nil
+}
+
+// scannableKeys merges this view's pending changes, the parent TState's
+// committed changes, and this view's prefetched storage -- the same
+// three layers getValue resolves through -- into the sorted, deduped,
+// permission-filtered set of keys in [start, end) an iterator may visit.
+func (tsv *TStateView) scannableKeys(start, end []byte) ([]string, error) {
+	if len(tsv.prefixes) == 0 {
+		return nil, ErrInvalidKeyOrPermission
+	}
+
+	seen := make(map[string]struct{})
+	var out []string
+	consider := func(k string) {
+		if _, ok := seen[k]; ok {
+			return
+		}
+		seen[k] = struct{}{}
+		if !withinRange(k, start, end) {
+			return
+		}
+		if !tsv.canScan(k) || !tsv.underPrefix(k) {
+			return
+		}
+		out = append(out, k)
+	}
+
+	for k := range tsv.pendingChangedKeys {
+		consider(k)
+	}
+	for k := range tsv.ts.changedKeys {
+		consider(k)
+	}
+	for k := range tsv.storage {
+		consider(k)
+	}
+
+	sort.Strings(out)
+	return out, nil
+}
+
+// canScan reports whether k falls under an exact key (with state.Read or
+// state.Scan) or the most specific declared prefix covering k carries
+// state.Scan -- see TStateView.checkScope for how overlapping prefixes
+// resolve.
+func (tsv *TStateView) canScan(k string) bool {
+	if tsv.unscoped {
+		return true
+	}
+	if perm, ok := tsv.scope[k]; ok {
+		return perm.HasPermission(state.Read) || perm.HasPermission(state.Scan)
+	}
+	return tsv.checkScope(k, state.Scan)
+}
+
+// underPrefix reports whether k falls under any prefix this view declared.
+func (tsv *TStateView) underPrefix(k string) bool {
+	for _, p := range tsv.prefixes {
+		if strings.HasPrefix(k, p.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func withinRange(k string, start, end []byte) bool {
+	if start != nil && k < string(start) {
+		return false
+	}
+	if end != nil && k >= string(end) {
+		return false
+	}
+	return true
+}
+
+// viewIterator walks the merged, pre-sorted key list a scannableKeys
+// call produced, resolving each key's current value (and marking it
+// read) lazily so a Release without exhausting the iterator doesn't pay
+// for keys it never visited.
+type viewIterator struct {
+	tsv     *TStateView
+	keys    []string
+	reverse bool
+	idx     int
+
+	key   []byte
+	value []byte
+	err   error
+}
+
+func (it *viewIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	for {
+		if it.reverse {
+			it.idx--
+			if it.idx < 0 {
+				return false
+			}
+		} else {
+			it.idx++
+			if it.idx >= len(it.keys) {
+				return false
+			}
+		}
+
+		k := it.keys[it.idx]
+		it.tsv.markRead(k)
+		v, err := it.tsv.getValue(k)
+		if errors.Is(err, database.ErrNotFound) {
+			// Tombstoned (or never had a value) since scannableKeys
+			// collected it; skip rather than surfacing a deletion.
+			continue
+		}
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		it.key, it.value = []byte(k), v
+		return true
+	}
+}
+
+func (it *viewIterator) Key() []byte   { return it.key }
+func (it *viewIterator) Value() []byte { return it.value }
+func (it *viewIterator) Error() error  { return it.err }
+func (it *viewIterator) Release()      {}
@@ -0,0 +1,45 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package tstate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/utils/maybe"
+	"github.com/ava-labs/avalanchego/utils/set"
+
+	"github.com/ava-labs/hypersdk/state"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestExportImportOpsRoundTrip checks that a fresh TState fed a delta via
+// ImportOps ends up with the same committed state as the TState ExportOps
+// captured it from -- this is what lets chain's engine journal a view and
+// replay it on restart instead of re-executing the chunks that produced
+// it.
+func TestExportImportOpsRoundTrip(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+	ts := New(10)
+
+	keySet := set.Of(
+		state.NewKey(key1str, state.Read, state.Write),
+		state.NewKey(key2str, state.Read, state.Write, state.Allocate),
+	)
+	tsv := ts.NewView(keySet, map[string][]byte{key1str: testVal})
+	require.NoError(tsv.Remove(ctx, key1))
+	require.NoError(tsv.Insert(ctx, key2, testVal))
+	tsv.Commit()
+
+	ops := ts.ExportOps()
+
+	replayed := New(10)
+	require.NoError(replayed.ImportOps(ops))
+
+	require.Equal(maybe.Nothing[[]byte](), replayed.changedKeys[key1str])
+	require.Equal(maybe.Some(testVal), replayed.changedKeys[key2str])
+	require.Equal(ts.changedKeys, replayed.changedKeys)
+}
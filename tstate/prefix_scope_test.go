@@ -0,0 +1,93 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package tstate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/utils/set"
+
+	"github.com/ava-labs/hypersdk/state"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestPrefixScopeHit checks that a prefix declared with Read/Write grants
+// GetValue/Insert/Remove on a concrete key under it, without that key
+// ever being declared on its own.
+func TestPrefixScopeHit(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+	ts := New(10)
+
+	prefix := state.NewPrefix("balances/", state.Read, state.Write)
+	k := []byte("balances/alice")
+	tsv := ts.NewView(set.Of(prefix), map[string][]byte{string(k): testVal})
+
+	v, err := tsv.GetValue(ctx, k)
+	require.NoError(err)
+	require.Equal(testVal, v)
+	require.NoError(tsv.Insert(ctx, k, []byte("new")))
+	require.NoError(tsv.Remove(ctx, k))
+}
+
+// TestPrefixScopeMiss checks that a key outside every declared prefix, or
+// a permission bit the covering prefix doesn't carry, is denied.
+func TestPrefixScopeMiss(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+	ts := New(10)
+
+	prefix := state.NewPrefix("balances/", state.Read)
+	tsv := ts.NewView(set.Of(prefix), map[string][]byte{"balances/alice": testVal})
+
+	// Outside the prefix entirely.
+	_, err := tsv.GetValue(ctx, []byte("other/alice"))
+	require.ErrorIs(err, ErrInvalidKeyOrPermission)
+
+	// Inside the prefix, but it only carries Read.
+	require.ErrorIs(tsv.Insert(ctx, []byte("balances/alice"), testVal), ErrInvalidKeyOrPermission)
+}
+
+// TestPrefixScopeOverlapDeniesConflictingBits checks that when two
+// declared prefixes cover the same key, the more specific one decides --
+// and if it lacks a bit the less specific one has, that bit is denied
+// rather than granted by the shorter prefix.
+func TestPrefixScopeOverlapDeniesConflictingBits(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+	ts := New(10)
+
+	// "balances/" grants Read+Write; the more specific "balances/frozen/"
+	// only grants Read, so a key under it may not be written even though
+	// the shorter prefix would otherwise allow it.
+	outer := state.NewPrefix("balances/", state.Read, state.Write)
+	inner := state.NewPrefix("balances/frozen/", state.Read)
+	tsv := ts.NewView(set.Of(outer, inner), map[string][]byte{"balances/frozen/bob": testVal})
+
+	v, err := tsv.GetValue(ctx, []byte("balances/frozen/bob"))
+	require.NoError(err)
+	require.Equal(testVal, v)
+	require.ErrorIs(tsv.Insert(ctx, []byte("balances/frozen/bob"), []byte("new")), ErrInvalidKeyOrPermission)
+
+	// A key under "balances/" but not "balances/frozen/" is unaffected.
+	require.NoError(tsv.Insert(ctx, []byte("balances/carol"), []byte("new")))
+}
+
+// TestPrefixScopeAmbiguousTieDenies checks that two equally-specific
+// prefixes covering the same key only grant what both agree on.
+func TestPrefixScopeAmbiguousTieDenies(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+	ts := New(10)
+
+	a := state.Key{Name: "balances/", Permission: state.Read, IsPrefix: true}
+	b := state.Key{Name: "balances/", Permission: state.Write, IsPrefix: true}
+	tsv := ts.NewView(set.Of(a, b), map[string][]byte{"balances/alice": testVal})
+
+	_, err := tsv.GetValue(ctx, []byte("balances/alice"))
+	require.ErrorIs(err, ErrInvalidKeyOrPermission)
+	require.ErrorIs(tsv.Insert(ctx, []byte("balances/alice"), testVal), ErrInvalidKeyOrPermission)
+}
@@ -0,0 +1,55 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package tstate
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrInvalidSavepoint is returned by RollbackTo/ReleaseSavepoint for a
+// SavepointID that was never issued by this view, or that has already
+// been rolled back past or released.
+var ErrInvalidSavepoint = errors.New("invalid or released savepoint")
+
+// SavepointID identifies a point in a TStateView's op log that
+// RollbackTo can later undo back to, without the caller needing to know
+// (or recompute) the raw op index Rollback takes. It is only valid for
+// the TStateView that issued it.
+type SavepointID int
+
+// Savepoint marks the view's current op index so that later code can
+// undo everything it does from here on with RollbackTo, without
+// disturbing ops applied before the savepoint. Savepoints nest: taking
+// one inside another and rolling back to the outer one implicitly
+// invalidates the inner one.
+func (tsv *TStateView) Savepoint() SavepointID {
+	id := SavepointID(len(tsv.savepoints))
+	tsv.savepoints = append(tsv.savepoints, len(tsv.ops))
+	return id
+}
+
+// RollbackTo undoes every op applied since id was taken, and invalidates
+// every savepoint taken after id (they no longer name a reachable point
+// in the log). id itself remains valid and may be rolled back to again.
+func (tsv *TStateView) RollbackTo(id SavepointID) error {
+	if int(id) < 0 || int(id) >= len(tsv.savepoints) {
+		return ErrInvalidSavepoint
+	}
+	tsv.Rollback(context.TODO(), tsv.savepoints[id])
+	tsv.savepoints = tsv.savepoints[:id+1]
+	return nil
+}
+
+// ReleaseSavepoint discards id: its ops stay applied, merged into the
+// view's (and any outer savepoint's) allocate/write tallies, and id (and
+// any savepoint nested inside it) can no longer be rolled back to or
+// released.
+func (tsv *TStateView) ReleaseSavepoint(id SavepointID) error {
+	if int(id) < 0 || int(id) >= len(tsv.savepoints) {
+		return ErrInvalidSavepoint
+	}
+	tsv.savepoints = tsv.savepoints[:id]
+	return nil
+}
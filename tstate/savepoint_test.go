@@ -0,0 +1,91 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package tstate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/utils/maybe"
+	"github.com/ava-labs/avalanchego/utils/set"
+
+	"github.com/ava-labs/hypersdk/state"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestSavepointRollback mirrors TestInsertRemoveInsert, but drives
+// rollback through named savepoints instead of raw OpIndex arithmetic.
+func TestSavepointRollback(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+	ts := New(10)
+
+	tsv := ts.NewView(set.Of(state.NewKey(key2str, state.Read, state.Write, state.Allocate)), map[string][]byte{})
+
+	sp1 := tsv.Savepoint()
+	require.NoError(tsv.Insert(ctx, key2, testVal))
+	allocates, writes := tsv.KeyOperations()
+	require.EqualValues(map[string]uint16{key2str: 2}, allocates)
+	require.EqualValues(map[string]uint16{key2str: 1}, writes)
+
+	sp2 := tsv.Savepoint()
+	testVal2 := []byte("blah")
+	require.NoError(tsv.Insert(ctx, key2, testVal2))
+	require.Equal(tsv.pendingChangedKeys[key2str], maybe.Some(testVal2))
+
+	// Roll back to sp2: undoes the second insert only.
+	require.NoError(tsv.RollbackTo(sp2))
+	require.Equal(tsv.pendingChangedKeys[key2str], maybe.Some(testVal))
+	allocates, writes = tsv.KeyOperations()
+	require.EqualValues(map[string]uint16{key2str: 2}, allocates)
+	require.EqualValues(map[string]uint16{key2str: 1}, writes)
+
+	// sp2 is still valid and can be rolled back to again.
+	require.NoError(tsv.RollbackTo(sp2))
+
+	// Roll back to sp1: undoes the first insert too, and invalidates
+	// sp2 (nested inside sp1).
+	require.NoError(tsv.RollbackTo(sp1))
+	require.NotContains(tsv.pendingChangedKeys, key2str)
+	require.Equal(0, tsv.OpIndex())
+	require.ErrorIs(tsv.RollbackTo(sp2), ErrInvalidSavepoint)
+
+	// sp1 remains valid.
+	require.NoError(tsv.RollbackTo(sp1))
+}
+
+// TestSavepointRelease checks that releasing a savepoint leaves its ops
+// applied (so KeyOperations still reflects them) while making it, and
+// anything nested inside it, unreachable for further rollback/release.
+func TestSavepointRelease(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+	ts := New(10)
+
+	tsv := ts.NewView(set.Of(state.NewKey(key2str, state.Read, state.Write, state.Allocate)), map[string][]byte{})
+
+	outer := tsv.Savepoint()
+	inner := tsv.Savepoint()
+	require.NoError(tsv.Insert(ctx, key2, testVal))
+
+	require.NoError(tsv.ReleaseSavepoint(inner))
+	require.ErrorIs(tsv.ReleaseSavepoint(inner), ErrInvalidSavepoint)
+	require.ErrorIs(tsv.RollbackTo(inner), ErrInvalidSavepoint)
+
+	// The insert inner guarded is still live, merged into the outer
+	// scope's tallies.
+	val, err := tsv.GetValue(ctx, key2)
+	require.NoError(err)
+	require.Equal(testVal, val)
+	_, writes := tsv.KeyOperations()
+	require.EqualValues(map[string]uint16{key2str: 1}, writes)
+
+	// Rolling back to outer still undoes it, since release only
+	// forgets the savepoint -- it doesn't commit the view.
+	require.NoError(tsv.RollbackTo(outer))
+	_, err = tsv.GetValue(ctx, key2)
+	require.ErrorIs(err, database.ErrNotFound)
+}
@@ -0,0 +1,126 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package tstate
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/maybe"
+
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/consts"
+	"github.com/ava-labs/hypersdk/utils"
+)
+
+var (
+	// ErrOutstandingViews is returned by Snapshot if any TStateView this
+	// TState has handed out hasn't yet been Commit-ed or Discard-ed: only
+	// committed state lives in changedKeys, so a dump taken now would
+	// silently miss whatever those views still have pending.
+	ErrOutstandingViews = errors.New("cannot snapshot TState with outstanding TStateViews")
+
+	// ErrSnapshotDigestMismatch is returned by LoadSnapshot if a dump's
+	// contents don't hash to the digest recorded alongside them.
+	ErrSnapshotDigestMismatch = errors.New("snapshot digest mismatch")
+)
+
+// Snapshot is a deterministic dump of every key/value a TState has
+// committed, plus Root, a digest over that data that two nodes holding
+// the same committed state will always agree on -- independent of
+// merkledb's own internal representation. It's meant to let an operator
+// dump-and-diff two nodes suspected of diverging, and to give state sync
+// a stable, streaming serialization to move around.
+type Snapshot struct {
+	Root   ids.ID
+	Values map[string]maybe.Maybe[[]byte]
+
+	encoded []byte
+}
+
+// Snapshot captures every key/value this TState has committed so far.
+// See ErrOutstandingViews for when it refuses to.
+func (ts *TState) Snapshot(_ context.Context) (*Snapshot, error) {
+	ts.outstandingMu.Lock()
+	outstanding := ts.outstanding
+	ts.outstandingMu.Unlock()
+	if outstanding != 0 {
+		return nil, ErrOutstandingViews
+	}
+
+	cs := ts.ChangeSet()
+	p := codec.NewWriter(consts.MaxInt)
+	cs.Marshal(p)
+	if err := p.Err(); err != nil {
+		return nil, err
+	}
+	encoded := p.Bytes()
+
+	return &Snapshot{
+		Root:    utils.ToID(encoded),
+		Values:  cs.Values,
+		encoded: encoded,
+	}, nil
+}
+
+// WriteTo writes s as a length-prefixed dump: s.Root, then a 4-byte
+// big-endian length, then s's ChangeSet encoding -- exactly what
+// LoadSnapshot expects back.
+func (s *Snapshot) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+
+	n, err := w.Write(s.Root[:])
+	written += int64(n)
+	if err != nil {
+		return written, err
+	}
+
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(s.encoded)))
+	n, err = w.Write(lenBuf[:])
+	written += int64(n)
+	if err != nil {
+		return written, err
+	}
+
+	n, err = w.Write(s.encoded)
+	written += int64(n)
+	return written, err
+}
+
+// LoadSnapshot reads a dump written by Snapshot.WriteTo and returns a
+// fresh TState seeded with its committed state, verifying it against the
+// digest recorded alongside it.
+func LoadSnapshot(r io.Reader) (*TState, error) {
+	var root ids.ID
+	if _, err := io.ReadFull(r, root[:]); err != nil {
+		return nil, err
+	}
+
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		return nil, err
+	}
+	encoded := make([]byte, binary.BigEndian.Uint32(lenBuf[:]))
+	if _, err := io.ReadFull(r, encoded); err != nil {
+		return nil, err
+	}
+
+	if got := utils.ToID(encoded); got != root {
+		return nil, fmt.Errorf("%w: got %s, want %s", ErrSnapshotDigestMismatch, got, root)
+	}
+
+	cs, err := UnmarshalChangeSet(codec.NewReader(encoded, consts.MaxInt))
+	if err != nil {
+		return nil, err
+	}
+
+	ts := New(len(cs.Values))
+	ts.ApplyChangeSet(cs)
+	return ts, nil
+}
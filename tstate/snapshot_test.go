@@ -0,0 +1,90 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package tstate
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/utils/maybe"
+	"github.com/ava-labs/avalanchego/utils/set"
+
+	"github.com/ava-labs/hypersdk/state"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestSnapshotOutstandingView checks that Snapshot refuses to run while a
+// view it handed out hasn't been Commit-ed or Discard-ed yet.
+func TestSnapshotOutstandingView(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+	ts := New(10)
+
+	tsv := ts.NewView(set.Of(state.NewKey(key2str, state.Read, state.Write, state.Allocate)), map[string][]byte{})
+	_, err := ts.Snapshot(ctx)
+	require.ErrorIs(err, ErrOutstandingViews)
+
+	require.NoError(tsv.Insert(ctx, key2, testVal))
+	tsv.Commit()
+
+	snap, err := ts.Snapshot(ctx)
+	require.NoError(err)
+	require.Equal(maybe.Some(testVal), snap.Values[key2str])
+}
+
+// TestSnapshotRoundTrip checks that a Snapshot survives WriteTo/LoadSnapshot
+// and that the loaded TState has the same committed state.
+func TestSnapshotRoundTrip(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+	ts := New(10)
+
+	keySet := set.Of(
+		state.NewKey(key1str, state.Read, state.Write),
+		state.NewKey(key2str, state.Read, state.Write, state.Allocate),
+	)
+	tsv := ts.NewView(keySet, map[string][]byte{key1str: testVal})
+	require.NoError(tsv.Remove(ctx, key1))
+	require.NoError(tsv.Insert(ctx, key2, testVal))
+	tsv.Commit()
+
+	snap, err := ts.Snapshot(ctx)
+	require.NoError(err)
+
+	var buf bytes.Buffer
+	n, err := snap.WriteTo(&buf)
+	require.NoError(err)
+	require.EqualValues(buf.Len(), n)
+
+	loaded, err := LoadSnapshot(&buf)
+	require.NoError(err)
+	require.Equal(ts.changedKeys, loaded.changedKeys)
+}
+
+// TestSnapshotDigestMismatch checks that LoadSnapshot rejects a dump whose
+// contents were tampered with after WriteTo.
+func TestSnapshotDigestMismatch(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+	ts := New(10)
+
+	tsv := ts.NewView(set.Of(state.NewKey(key2str, state.Read, state.Write, state.Allocate)), map[string][]byte{})
+	require.NoError(tsv.Insert(ctx, key2, testVal))
+	tsv.Commit()
+
+	snap, err := ts.Snapshot(ctx)
+	require.NoError(err)
+
+	var buf bytes.Buffer
+	_, err = snap.WriteTo(&buf)
+	require.NoError(err)
+
+	corrupt := buf.Bytes()
+	corrupt[len(corrupt)-1] ^= 0xFF
+
+	_, err = LoadSnapshot(bytes.NewReader(corrupt))
+	require.ErrorIs(err, ErrSnapshotDigestMismatch)
+}
@@ -0,0 +1,241 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package tstate provides a transactional view over state.Immutable: each
+// transaction gets its own TStateView, scoped to the keys it declared,
+// that buffers Insert/Remove against a shared TState until Commit. That
+// lets a block builder speculatively execute many transactions over the
+// same base state and only pay for a single merkle diff at the end.
+package tstate
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/ava-labs/avalanchego/utils/maybe"
+	"github.com/ava-labs/avalanchego/utils/set"
+	"github.com/ava-labs/avalanchego/x/merkledb"
+
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/consts"
+	"github.com/ava-labs/hypersdk/state"
+	"github.com/ava-labs/hypersdk/trace"
+)
+
+var (
+	ErrInvalidKeyOrPermission = errors.New("invalid key or permission")
+	ErrInvalidKeyValue        = errors.New("invalid key value")
+	ErrAllocationDisabled     = errors.New("allocation disabled")
+)
+
+// TState accumulates every change committed by the TStateViews it hands
+// out, in commit order, so that (a) a later view can see an earlier
+// view's writes without re-fetching them from the underlying database,
+// and (b) the whole batch can be flushed to a merkledb.View in one shot
+// via ExportMerkleDBView.
+type TState struct {
+	ops []*op
+
+	// changedKeys is the net effect, across every view committed so far,
+	// of this TState's lifetime: the value each touched key should now
+	// resolve to (maybe.Nothing for a deletion). It is consulted by
+	// later views ahead of their own (potentially stale) prefetched
+	// storage.
+	changedKeys map[string]maybe.Maybe[[]byte]
+
+	// versions counts how many times each key has been committed by a
+	// view, so RunConcurrent can tell whether a key a view read has
+	// changed since it read it. Keys never committed to aren't present
+	// (equivalent to version 0).
+	versions map[string]uint64
+
+	// outstanding counts views handed out by NewView/NewConcurrentView
+	// that haven't yet been Commit-ed or Discard-ed. Snapshot refuses to
+	// run while it's nonzero. outstandingMu guards it, since
+	// RunConcurrent hands out views from concurrent goroutines.
+	outstandingMu sync.Mutex
+	outstanding   int
+}
+
+// New creates a TState. changedKeysHint sizes the initial allocation of
+// the cumulative change map and should be a rough estimate of how many
+// distinct keys the batch will touch (e.g. 2x the number of txs).
+func New(changedKeysHint int) *TState {
+	return &TState{
+		changedKeys: make(map[string]maybe.Maybe[[]byte], changedKeysHint),
+		versions:    make(map[string]uint64, changedKeysHint),
+	}
+}
+
+// NewView returns a TStateView scoped to scope, seeded with storage (the
+// caller's prefetch of scope's exact keys). scope may mix exact keys
+// (state.NewKey) and prefixes (state.NewPrefix); Iterator/ReverseIterator
+// only visit keys under the latter, while Has also accepts an exact key
+// declared with state.Read or state.Scan.
+func (ts *TState) NewView(scope set.Set[state.Key], storage map[string][]byte) *TStateView {
+	tsv := &TStateView{
+		ts:      ts,
+		storage: storage,
+
+		scope: make(map[string]state.Permissions, len(scope)),
+
+		pendingChangedKeys: make(map[string]maybe.Maybe[[]byte]),
+		allocates:          make(map[string]uint16),
+		writes:             make(map[string]uint16),
+		reads:              make(map[string]struct{}),
+		readVersions:       make(map[string]uint64),
+
+		allocationEnabled: true,
+	}
+	for key := range scope {
+		if key.IsPrefix {
+			tsv.prefixes = append(tsv.prefixes, key)
+			continue
+		}
+		tsv.scope[key.Name] = key.Permission
+	}
+	ts.outstandingMu.Lock()
+	ts.outstanding++
+	ts.outstandingMu.Unlock()
+	return tsv
+}
+
+// NewViewWithRoles is NewView for callers that prefer to declare scope
+// as role assignments (see state.Role) rather than hand-built
+// state.Key values: each entry becomes state.NewKeyWithRole(name, role).
+func (ts *TState) NewViewWithRoles(roles map[string]state.Role, storage map[string][]byte) *TStateView {
+	scope := make(set.Set[state.Key], len(roles))
+	for name, role := range roles {
+		scope.Add(state.NewKeyWithRole(name, role))
+	}
+	return ts.NewView(scope, storage)
+}
+
+// NewConcurrentView returns a TStateView like NewView, but with every
+// permission check bypassed: RunConcurrent uses it for speculative
+// execution, where a tx's read/write set isn't declared ahead of time and
+// is instead tracked (as a version per key read, via readVersions) and
+// checked for conflicts at commit time.
+func (ts *TState) NewConcurrentView(storage map[string][]byte) *TStateView {
+	tsv := ts.NewView(set.Set[state.Key]{}, storage)
+	tsv.unscoped = true
+	return tsv
+}
+
+// conflicted reports whether any key tsv read has been committed to since
+// tsv captured its version for that key.
+func (ts *TState) conflicted(tsv *TStateView) bool {
+	for k, v := range tsv.readVersions {
+		if ts.versions[k] != v {
+			return true
+		}
+	}
+	return false
+}
+
+// RunConcurrent runs each of txs against its own speculative TStateView
+// (see NewConcurrentView), concurrently, then commits them one at a time
+// in the order given -- the software-transactional-memory pattern etcd's
+// STM uses: a view commits cleanly if every key it read is still at the
+// version it read it at; otherwise an earlier commit in this same batch
+// invalidated it, so its closure is re-run against a fresh view (seeded
+// by the now-current committed state) and retried until it commits
+// without conflict. Every successful attempt still goes through the
+// ordinary, unconditional TStateView.Commit underneath -- RunConcurrent
+// only adds the parallel speculation and conflict retry on top.
+func (ts *TState) RunConcurrent(ctx context.Context, storage map[string][]byte, txs []func(ctx context.Context, tsv *TStateView) error) []error {
+	errs := make([]error, len(txs))
+	views := make([]*TStateView, len(txs))
+
+	var wg sync.WaitGroup
+	wg.Add(len(txs))
+	for i, tx := range txs {
+		i, tx := i, tx
+		go func() {
+			defer wg.Done()
+			tsv := ts.NewConcurrentView(storage)
+			errs[i] = tx(ctx, tsv)
+			views[i] = tsv
+		}()
+	}
+	wg.Wait()
+
+	for i, tx := range txs {
+		if errs[i] != nil {
+			views[i].Discard()
+			continue
+		}
+		for ts.conflicted(views[i]) {
+			views[i].Discard()
+			tsv := ts.NewConcurrentView(storage)
+			if err := tx(ctx, tsv); err != nil {
+				errs[i] = err
+				views[i] = tsv
+				break
+			}
+			views[i] = tsv
+		}
+		if errs[i] != nil {
+			views[i].Discard()
+			continue
+		}
+		views[i].Commit()
+	}
+	return errs
+}
+
+// OpIndex returns the number of operations committed across every view
+// this TState has handed out.
+func (ts *TState) OpIndex() int {
+	return len(ts.ops)
+}
+
+// PendingChanges returns the number of distinct keys with a net change
+// committed so far.
+func (ts *TState) PendingChanges() int {
+	return len(ts.changedKeys)
+}
+
+// ChangeSet returns every change committed so far, across every view this
+// TState has handed out, as a single ChangeSet.
+func (ts *TState) ChangeSet() ChangeSet {
+	return ChangeSet{Values: ts.changedKeys}
+}
+
+// ExportOps encodes every change committed so far as an opaque delta that
+// ImportOps can later merge into a fresh TState without re-executing
+// whatever produced it -- what chain's engine journals per in-memory view
+// so a restart can replay instead of re-processing chunks.
+func (ts *TState) ExportOps() []byte {
+	p := codec.NewWriter(consts.MaxInt)
+	ts.ChangeSet().Marshal(p)
+	if err := p.Err(); err != nil {
+		panic(err)
+	}
+	return p.Bytes()
+}
+
+// ImportOps merges a delta previously produced by ExportOps directly into
+// ts, the same way ApplyChangeSet merges a TStateView's ChangeSet.
+func (ts *TState) ImportOps(ops []byte) error {
+	p := codec.NewReader(ops, consts.NetworkSizeLimit)
+	cs, err := UnmarshalChangeSet(p)
+	if err != nil {
+		return err
+	}
+	ts.ApplyChangeSet(cs)
+	return nil
+}
+
+// ExportMerkleDBView flushes every change committed so far into a single
+// merkledb.View over db, ready to be committed or further chained from.
+func (ts *TState) ExportMerkleDBView(ctx context.Context, tracer trace.Tracer, db merkledb.MerkleDB) (merkledb.View, error) {
+	ctx, span := tracer.Start(ctx, "TState.ExportMerkleDBView")
+	defer span.End()
+
+	return db.NewView(ctx, merkledb.ViewChanges{
+		MapOps:       ts.ChangeSet().Values,
+		ConsumeBytes: true,
+	})
+}
@@ -121,7 +121,7 @@ func TestInsertNew(t *testing.T) {
 	ts := New(10)
 
 	// SetScope
-	tsv := ts.NewView(set.Of(state.NewKey(string(testKey), state.Read, state.Write)), map[string][]byte{})
+	tsv := ts.NewView(set.Of(state.NewKey(string(testKey), state.Read, state.Write, state.Allocate)), map[string][]byte{})
 
 	// Test Disable Allocate
 	tsv.DisableAllocation()
@@ -191,7 +191,7 @@ func TestInsertRemoveInsert(t *testing.T) {
 	ts := New(10)
 
 	// SetScope and add
-	tsv := ts.NewView(set.Of(state.NewKey(key2str, state.Read, state.Write)), map[string][]byte{})
+	tsv := ts.NewView(set.Of(state.NewKey(key2str, state.Read, state.Write, state.Allocate)), map[string][]byte{})
 	require.Equal(0, ts.OpIndex())
 
 	// Insert key for first time
@@ -404,7 +404,7 @@ func TestRemoveInsertRollback(t *testing.T) {
 	ctx := context.TODO()
 
 	// Insert
-	tsv := ts.NewView(set.Of(state.NewKey(string(testKey), state.Read, state.Write)), map[string][]byte{})
+	tsv := ts.NewView(set.Of(state.NewKey(string(testKey), state.Read, state.Write, state.Allocate)), map[string][]byte{})
 	require.NoError(tsv.Insert(ctx, testKey, testVal))
 	v, err := tsv.GetValue(ctx, testKey)
 	require.NoError(err)
@@ -442,7 +442,7 @@ func TestRestoreInsert(t *testing.T) {
 	ts := New(10)
 	ctx := context.TODO()
 	keys := [][]byte{key1, key2, key3}
-	keySet := set.Of(state.NewKey(key1str, state.Read, state.Write), state.NewKey(key2str, state.Read, state.Write), state.NewKey(key3str, state.Read, state.Write))
+	keySet := set.Of(state.NewKey(key1str, state.Read, state.Write, state.Allocate), state.NewKey(key2str, state.Read, state.Write, state.Allocate), state.NewKey(key3str, state.Read, state.Write, state.Allocate))
 	vals := [][]byte{[]byte("val1"), []byte("val2"), []byte("val3")}
 
 	// Store keys
@@ -550,7 +550,7 @@ func TestCreateView(t *testing.T) {
 		t.Fatal(err)
 	}
 	keys := [][]byte{key1, key2, key3}
-	keySet := set.Of(state.NewKey(key1str, state.Read, state.Write), state.NewKey(key2str, state.Read, state.Write), state.NewKey(key3str, state.Read, state.Write))
+	keySet := set.Of(state.NewKey(key1str, state.Read, state.Write, state.Allocate), state.NewKey(key2str, state.Read, state.Write, state.Allocate), state.NewKey(key3str, state.Read, state.Write, state.Allocate))
 	vals := [][]byte{[]byte("val1"), []byte("val2"), []byte("val3")}
 
 	// Add
@@ -669,14 +669,22 @@ func TestInsertWithInvalidPermission(t *testing.T) {
 	tsv := ts.NewView(set.Of(key), map[string][]byte{})
 	require.ErrorIs(tsv.Insert(ctx, []byte(key.Name), []byte("val")), ErrInvalidKeyOrPermission)
 	require.False(key.Permission.HasPermission(state.Read))
-	require.False(key.Permission.HasPermission(state.Write))
+	require.False(key.Permission.HasPermission(state.Allocate))
 
 	// Insert key that has read permission
 	key1 := state.NewKey("name1", state.Read)
 	tsv1 := ts.NewView(set.Of(key1), map[string][]byte{})
 	require.ErrorIs(tsv1.Insert(ctx, []byte(key1.Name), []byte("val1")), ErrInvalidKeyOrPermission)
 	require.True(key1.Permission.HasPermission(state.Read))
-	require.False(key1.Permission.HasPermission(state.Write))
+	require.False(key1.Permission.HasPermission(state.Allocate))
+
+	// Insert a new key that only has write permission; write alone does not
+	// let a transaction create a key that doesn't exist yet.
+	key2 := state.NewKey("name2", state.Write)
+	tsv2 := ts.NewView(set.Of(key2), map[string][]byte{})
+	require.ErrorIs(tsv2.Insert(ctx, []byte(key2.Name), []byte("val2")), ErrInvalidKeyOrPermission)
+	require.True(key2.Permission.HasPermission(state.Write))
+	require.False(key2.Permission.HasPermission(state.Allocate))
 }
 
 func TestInsertWithValidPermission(t *testing.T) {
@@ -684,19 +692,56 @@ func TestInsertWithValidPermission(t *testing.T) {
 	ctx := context.TODO()
 	ts := New(10)
 
-	// Insert key that has write permissions
-	key := state.NewKey("name", state.Write)
+	// Insert a new key that has allocate permission
+	key := state.NewKey("name", state.Allocate)
 	tsv := ts.NewView(set.Of(key), map[string][]byte{})
 	require.NoError(tsv.Insert(ctx, []byte(key.Name), []byte("val")))
 	require.False(key.Permission.HasPermission(state.Read))
-	require.True(key.Permission.HasPermission(state.Write))
+	require.True(key.Permission.HasPermission(state.Allocate))
 
-	// Insert key that has read write permission
-	key1 := state.NewKey("name1", state.Read, state.Write)
+	// Insert a new key that has read write allocate permission
+	key1 := state.NewKey("name1", state.Read, state.Write, state.Allocate)
 	tsv1 := ts.NewView(set.Of(key1), map[string][]byte{})
 	require.NoError(tsv1.Insert(ctx, []byte(key1.Name), []byte("val1")))
 	require.True(key1.Permission.HasPermission(state.Read))
 	require.True(key1.Permission.HasPermission(state.Write))
+
+	// Once committed, write permission alone (no allocate) is enough to
+	// update the now-existing key.
+	tsv1.Commit()
+	key2 := state.NewKey("name1", state.Read, state.Write)
+	tsv2 := ts.NewView(set.Of(key2), map[string][]byte{})
+	require.NoError(tsv2.Insert(ctx, []byte(key2.Name), []byte("updated")))
+}
+
+// TestInsertRequiresAllocateForNewKey checks that Insert requires
+// state.Allocate (not just state.Write) for a key that doesn't yet
+// resolve to a value anywhere, and that state.Write alone is sufficient
+// once the key exists.
+func TestInsertRequiresAllocateForNewKey(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+	ts := New(10)
+
+	// Write alone cannot create the key.
+	writeOnly := state.NewKey(string(testKey), state.Read, state.Write)
+	tsv := ts.NewView(set.Of(writeOnly), map[string][]byte{})
+	require.ErrorIs(tsv.Insert(ctx, testKey, testVal), ErrInvalidKeyOrPermission)
+
+	// Allocate (without Write) creates the key.
+	allocateOnly := state.NewKey(string(testKey), state.Read, state.Allocate)
+	tsv = ts.NewView(set.Of(allocateOnly), map[string][]byte{})
+	require.NoError(tsv.Insert(ctx, testKey, testVal))
+	tsv.Commit()
+
+	// Now that the key exists, Write alone is enough to update it.
+	tsv = ts.NewView(set.Of(writeOnly), map[string][]byte{})
+	require.NoError(tsv.Insert(ctx, testKey, []byte("newVal")))
+
+	// But Allocate alone is not enough to update an existing key -- that
+	// still requires Write.
+	tsv = ts.NewView(set.Of(allocateOnly), map[string][]byte{})
+	require.ErrorIs(tsv.Insert(ctx, testKey, []byte("anotherVal")), ErrInvalidKeyOrPermission)
 }
 
 func TestRemoveWithInvalidPermission(t *testing.T) {
@@ -741,24 +786,73 @@ func TestRemoveWithValidPermission(t *testing.T) {
 
 func TestWithOutOfBoundPermission(t *testing.T) {
 	require := require.New(t)
+
+	// A permission bit that was never registered with defaultRegistry is
+	// a construction-time error now, not a key that silently ends up
+	// with no effective permissions.
+	outOfBoundsPerm := state.Permissions(100)
+	require.Panics(func() {
+		state.NewKey("test", outOfBoundsPerm)
+	})
+}
+
+// TestRoleDerivedKeyMatchesHandConstructed checks that a key built from a
+// state.Role behaves identically to one built from the equivalent
+// state.NewKey call.
+func TestRoleDerivedKeyMatchesHandConstructed(t *testing.T) {
+	require := require.New(t)
 	ctx := context.TODO()
 	ts := New(10)
 
-	// Key specifies a permission bit that is out of bounds
-	outOfBoundsPerm := 100
-	key := state.NewKey("test", outOfBoundsPerm)
+	transferRole := state.NewRole(state.Read, state.Write)
+	key := state.NewKeyWithRole("test", transferRole)
+	handKey := state.NewKey("test", state.Read, state.Write)
+	require.Equal(handKey.Permission, key.Permission)
+
 	tsv := ts.NewView(set.Of(key), map[string][]byte{key.Name: testVal})
+	v, err := tsv.GetValue(ctx, []byte(key.Name))
+	require.NoError(err)
+	require.Equal(testVal, v)
+	require.NoError(tsv.Remove(ctx, []byte(key.Name)))
+}
 
-	// Can't GetValue/Insert/Remove then
-	_, err := tsv.GetValue(ctx, []byte(key.Name))
-	require.ErrorIs(ErrInvalidKeyOrPermission, err)
-	require.ErrorIs(tsv.Insert(ctx, []byte(key.Name), []byte("val")), ErrInvalidKeyOrPermission)
-	require.ErrorIs(tsv.Remove(ctx, []byte(key.Name)), ErrInvalidKeyOrPermission)
+// TestRoleUnionAndDeny checks that Union combines two roles' permissions
+// and that Deny withholds a bit even after a Union that would otherwise
+// grant it.
+func TestRoleUnionAndDeny(t *testing.T) {
+	require := require.New(t)
 
-	// We also won't have any Read/Write permissions
-	require.False(key.Permission.HasPermission(state.Read))
-	require.False(key.Permission.HasPermission(state.Write))
+	readOnly := state.NewRole(state.Read)
+	writeOnly := state.NewRole(state.Write)
+	combined := readOnly.Union(writeOnly)
+	require.True(combined.Permission().HasPermission(state.Read))
+	require.True(combined.Permission().HasPermission(state.Write))
 
-	// This permission that we set and now want to access will be false
-	require.False(key.Permission.HasPermission(outOfBoundsPerm))
+	noWrite := combined.Deny(state.Write)
+	require.True(noWrite.Permission().HasPermission(state.Read))
+	require.False(noWrite.Permission().HasPermission(state.Write))
+
+	// A later Union with a role that itself grants Write still doesn't
+	// restore it -- the deny-list is checked after composition.
+	stillNoWrite := noWrite.Union(writeOnly)
+	require.False(stillNoWrite.Permission().HasPermission(state.Write))
+}
+
+// TestNewViewWithRoles checks that TState.NewViewWithRoles produces a
+// view whose scope matches what building the same roles into keys by
+// hand would.
+func TestNewViewWithRoles(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+	ts := New(10)
+
+	transferRole := state.NewRole(state.Read, state.Write)
+	tsv := ts.NewViewWithRoles(map[string]state.Role{
+		string(testKey): transferRole,
+	}, map[string][]byte{string(testKey): testVal})
+
+	v, err := tsv.GetValue(ctx, testKey)
+	require.NoError(err)
+	require.Equal(testVal, v)
+	require.NoError(tsv.Insert(ctx, testKey, []byte("updated")))
 }
@@ -0,0 +1,368 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package tstate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/utils/maybe"
+
+	"github.com/ava-labs/hypersdk/keys"
+	"github.com/ava-labs/hypersdk/state"
+)
+
+// op records enough of a key's prior state for Rollback to undo a single
+// Insert/Remove exactly, without needing to replay every earlier op.
+type op struct {
+	k string
+
+	// pastV is whatever GetValue(k) would have returned immediately
+	// before this op (nil if it would have returned ErrNotFound).
+	pastV []byte
+
+	// pastPending is pendingChangedKeys[k] immediately before this op,
+	// or nil if there was no entry -- Rollback deletes the entry
+	// entirely in that case rather than restoring it to a value.
+	pastPending *maybe.Maybe[[]byte]
+
+	// pastAllocates/pastWrites are the prior entries (if any) in the
+	// view's allocate/write counters for k.
+	pastAllocates *uint16
+	pastWrites    *uint16
+}
+
+// TStateView is a single transaction's (or other caller's) scoped view
+// over a TState: GetValue/Insert/Remove only succeed for keys declared
+// in scope, with the permission they require, and every change is
+// buffered locally until Commit merges it into the parent TState.
+type TStateView struct {
+	ts      *TState
+	storage map[string][]byte
+
+	// scope holds the permissions for every exactly-declared key. Prefix
+	// declarations (state.NewPrefix) live in prefixes instead, since
+	// they don't name a single key to index by.
+	scope    map[string]state.Permissions
+	prefixes []state.Key
+
+	// unscoped, set by NewConcurrentView, bypasses scope/prefixes
+	// entirely: every key is implicitly authorized for every permission.
+	// Used for speculative execution, where the read/write set isn't
+	// known ahead of time and is instead checked for conflicts later via
+	// readVersions.
+	unscoped bool
+
+	ops []*op
+
+	// savepoints holds, for each outstanding SavepointID, the op index
+	// it was taken at; the ID itself is the entry's position in this
+	// slice, so truncating it both pops and invalidates savepoints.
+	savepoints []int
+
+	pendingChangedKeys map[string]maybe.Maybe[[]byte]
+	allocates          map[string]uint16
+	writes             map[string]uint16
+
+	// reads is every key visited by GetValue, Has, or an iterator, used
+	// by callers that need to account for reads beyond scope's declared
+	// keys (e.g. fee charging, conflict detection).
+	reads map[string]struct{}
+
+	// readVersions is, for every key in reads, the TState's version for
+	// that key at the moment it was first read by this view -- i.e. what
+	// TState.conflicted compares against the current version to tell
+	// whether this view's reads are still valid.
+	readVersions map[string]uint64
+
+	allocationEnabled bool
+}
+
+// DisableAllocation prevents Insert from creating a key that doesn't yet
+// exist anywhere in this view's chain of storage/parent-TState/pending
+// state; it can still update existing keys.
+func (tsv *TStateView) DisableAllocation() {
+	tsv.allocationEnabled = false
+}
+
+// EnableAllocation reverses DisableAllocation. Views start with
+// allocation enabled.
+func (tsv *TStateView) EnableAllocation() {
+	tsv.allocationEnabled = true
+}
+
+// OpIndex returns the number of operations applied to this view so far.
+func (tsv *TStateView) OpIndex() int {
+	return len(tsv.ops)
+}
+
+// PendingChanges returns the number of distinct keys with a net change
+// in this view.
+func (tsv *TStateView) PendingChanges() int {
+	return len(tsv.pendingChangedKeys)
+}
+
+// KeyOperations returns, for every key this view has newly allocated,
+// the number of chunks it declared (from its key suffix); and for every
+// key with a net write (including a deletion, recorded as 0), a count of
+// 1. Both are consumed by fee accounting.
+func (tsv *TStateView) KeyOperations() (map[string]uint16, map[string]uint16) {
+	return tsv.allocates, tsv.writes
+}
+
+// Reads returns every key this view has visited via GetValue, Has, or an
+// iterator.
+func (tsv *TStateView) Reads() map[string]struct{} {
+	return tsv.reads
+}
+
+func (tsv *TStateView) markRead(k string) {
+	tsv.reads[k] = struct{}{}
+	if _, ok := tsv.readVersions[k]; !ok {
+		tsv.readVersions[k] = tsv.ts.versions[k]
+	}
+}
+
+// checkScope reports whether k may be accessed with require: unscoped
+// views (see NewConcurrentView) always pass; otherwise an exact key
+// declared in scope is authoritative on its own, and failing that, k
+// falls back to whichever declared prefix covering it is most specific
+// (longest Name). Two equally-specific covering prefixes intersect their
+// permissions rather than either one deciding alone, so a bit only one of
+// them grants is denied -- ambiguous coverage fails closed. A less
+// specific prefix's permission is never used to grant what a more
+// specific one lacks.
+func (tsv *TStateView) checkScope(k string, require state.Permissions) bool {
+	if tsv.unscoped {
+		return true
+	}
+	if perm, ok := tsv.scope[k]; ok {
+		return perm.HasPermission(require)
+	}
+
+	bestLen := -1
+	var bestPerm state.Permissions
+	for _, p := range tsv.prefixes {
+		if !strings.HasPrefix(k, p.Name) {
+			continue
+		}
+		switch {
+		case len(p.Name) > bestLen:
+			bestLen = len(p.Name)
+			bestPerm = p.Permission
+		case len(p.Name) == bestLen:
+			bestPerm &= p.Permission
+		}
+	}
+	if bestLen < 0 {
+		return false
+	}
+	return bestPerm.HasPermission(require)
+}
+
+// getValue resolves k through this view's own pending changes, then
+// everything the parent TState has committed so far, then this view's
+// prefetched storage -- in that order, so a later view always sees an
+// earlier view's committed writes even if its own prefetch predates
+// them.
+func (tsv *TStateView) getValue(k string) ([]byte, error) {
+	if v, ok := tsv.pendingChangedKeys[k]; ok {
+		if v.IsNothing() {
+			return nil, database.ErrNotFound
+		}
+		return v.Value(), nil
+	}
+	if v, ok := tsv.persistedValue(k); ok {
+		return v, nil
+	}
+	return nil, database.ErrNotFound
+}
+
+// persistedValue resolves k through the parent TState's committed
+// changes and this view's prefetched storage, ignoring this view's own
+// uncommitted pending changes. It is what Insert/Remove compare against
+// to decide whether a key is being allocated for the first time.
+func (tsv *TStateView) persistedValue(k string) ([]byte, bool) {
+	if v, ok := tsv.ts.changedKeys[k]; ok {
+		if v.IsNothing() {
+			return nil, false
+		}
+		return v.Value(), true
+	}
+	if v, ok := tsv.storage[k]; ok {
+		return v, true
+	}
+	return nil, false
+}
+
+// GetValue returns the current value of key, which must be declared in
+// scope with state.Read.
+func (tsv *TStateView) GetValue(_ context.Context, key []byte) ([]byte, error) {
+	k := string(key)
+	if !tsv.checkScope(k, state.Read) {
+		return nil, ErrInvalidKeyOrPermission
+	}
+	tsv.markRead(k)
+	return tsv.getValue(k)
+}
+
+// Insert sets key's value. If key does not currently resolve to a value
+// anywhere (the parent TState, this view's storage, or this view's own
+// pending changes) this is an allocation: it must be declared in scope
+// with state.Allocate, and fails with ErrAllocationDisabled if this view
+// has DisableAllocation set. Otherwise key must be declared with
+// state.Write.
+func (tsv *TStateView) Insert(_ context.Context, key []byte, value []byte) error {
+	k := string(key)
+	chunks, validKey := keys.MaxChunks(key)
+	if !validKey || chunks == 0 {
+		return ErrInvalidKeyValue
+	}
+
+	persisted, hasPersisted := tsv.persistedValue(k)
+	_, alreadyPending := tsv.pendingChangedKeys[k]
+	isNew := !hasPersisted && !alreadyPending
+
+	require := state.Write
+	if isNew {
+		require = state.Allocate
+	}
+	if !tsv.checkScope(k, require) {
+		return ErrInvalidKeyOrPermission
+	}
+
+	current, currentErr := tsv.getValue(k)
+	if currentErr == nil && bytes.Equal(current, value) {
+		// Already resolves to this value; nothing to do.
+		return nil
+	}
+
+	if isNew && !tsv.allocationEnabled {
+		return ErrAllocationDisabled
+	}
+
+	o := tsv.snapshot(k, current, currentErr)
+
+	if hasPersisted && bytes.Equal(persisted, value) {
+		// This undoes every change this view has made to k, reverting
+		// it to what it resolves to outside this view.
+		delete(tsv.pendingChangedKeys, k)
+		delete(tsv.allocates, k)
+		delete(tsv.writes, k)
+	} else {
+		tsv.pendingChangedKeys[k] = maybe.Some(value)
+		if !alreadyPending {
+			if !hasPersisted {
+				tsv.allocates[k] = chunks
+			}
+			tsv.writes[k] = 1
+		}
+	}
+
+	tsv.ops = append(tsv.ops, o)
+	return nil
+}
+
+// Remove deletes key, which must be declared in scope with state.Write.
+// Removing a key that does not currently resolve to a value is a no-op.
+func (tsv *TStateView) Remove(_ context.Context, key []byte) error {
+	k := string(key)
+	if !tsv.checkScope(k, state.Write) {
+		return ErrInvalidKeyOrPermission
+	}
+
+	current, currentErr := tsv.getValue(k)
+	if errors.Is(currentErr, database.ErrNotFound) {
+		return nil
+	} else if currentErr != nil {
+		return currentErr
+	}
+
+	_, hasPersisted := tsv.persistedValue(k)
+
+	o := tsv.snapshot(k, current, currentErr)
+
+	if !hasPersisted {
+		// k only ever existed as a pending allocation in this view;
+		// removing it undoes that allocation entirely.
+		delete(tsv.pendingChangedKeys, k)
+		delete(tsv.allocates, k)
+		delete(tsv.writes, k)
+	} else {
+		tsv.pendingChangedKeys[k] = maybe.Nothing[[]byte]()
+		tsv.writes[k] = 0
+	}
+
+	tsv.ops = append(tsv.ops, o)
+	return nil
+}
+
+// snapshot captures k's state immediately before an op, for Rollback.
+func (tsv *TStateView) snapshot(k string, current []byte, currentErr error) *op {
+	o := &op{k: k}
+	if currentErr == nil {
+		o.pastV = current
+	}
+	if pv, ok := tsv.pendingChangedKeys[k]; ok {
+		o.pastPending = &pv
+	}
+	if pa, ok := tsv.allocates[k]; ok {
+		o.pastAllocates = &pa
+	}
+	if pw, ok := tsv.writes[k]; ok {
+		o.pastWrites = &pw
+	}
+	return o
+}
+
+// Commit merges every change this view made into the parent TState,
+// making it visible to views created afterwards. It hands the view's
+// ChangeSet to the parent directly, rather than replaying this view's op
+// log (ops exist for this view's own Rollback, not for Commit).
+func (tsv *TStateView) Commit() {
+	tsv.ts.ops = append(tsv.ts.ops, tsv.ops...)
+	tsv.ts.ApplyChangeSet(tsv.ChangeSet())
+	tsv.ts.outstandingMu.Lock()
+	tsv.ts.outstanding--
+	tsv.ts.outstandingMu.Unlock()
+}
+
+// Discard abandons every uncommitted change this view made without
+// merging any of it into the parent TState. It exists to balance
+// NewView's outstanding count for a view the caller decided not to
+// commit (e.g. a tx that failed execution), so Snapshot can still run
+// once every view handed out has been resolved one way or the other.
+func (tsv *TStateView) Discard() {
+	tsv.ts.outstandingMu.Lock()
+	tsv.ts.outstanding--
+	tsv.ts.outstandingMu.Unlock()
+}
+
+// Rollback undoes every op from the end of this view's log back to (but
+// not including) restoreOps, leaving OpIndex() == restoreOps.
+func (tsv *TStateView) Rollback(_ context.Context, restoreOps int) {
+	for i := len(tsv.ops) - 1; i >= restoreOps; i-- {
+		o := tsv.ops[i]
+
+		if o.pastPending == nil {
+			delete(tsv.pendingChangedKeys, o.k)
+		} else {
+			tsv.pendingChangedKeys[o.k] = *o.pastPending
+		}
+		if o.pastAllocates == nil {
+			delete(tsv.allocates, o.k)
+		} else {
+			tsv.allocates[o.k] = *o.pastAllocates
+		}
+		if o.pastWrites == nil {
+			delete(tsv.writes, o.k)
+		} else {
+			tsv.writes[o.k] = *o.pastWrites
+		}
+	}
+	tsv.ops = tsv.ops[:restoreOps]
+}
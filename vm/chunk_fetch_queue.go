@@ -0,0 +1,108 @@
+package vm
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/ava-labs/avalanchego/ids"
+)
+
+// fetchWorkers bounds how many chunks RequestChunks fetches concurrently
+// across all peers for a single call.
+const fetchWorkers = 8
+
+// maxInFlightPerPeer bounds how many outstanding chunk requests RequestChunks
+// will have open against a single peer at once, so one slow peer can't
+// monopolize every worker.
+const maxInFlightPerPeer = 4
+
+// fetchTask is one chunk a worker still needs to retrieve, keyed by chunkID
+// so taskQueue can dedupe and track completion.
+type fetchTask struct {
+	chunkID ids.ID
+	height  *uint64
+	retries int
+}
+
+// peerConn tracks in-flight capacity and a simple reputation score for one
+// remote peer. Workers prefer higher-scored peers with spare capacity;
+// a timed-out or empty response decrements the score instead of the
+// worker blindly sleeping, so a bad peer is deprioritized on the very
+// next task a worker reserves.
+type peerConn struct {
+	nodeID ids.NodeID
+	sem    chan struct{} // buffered to maxInFlightPerPeer
+	score  int64         // accessed atomically
+}
+
+func newPeerConn(nodeID ids.NodeID) *peerConn {
+	return &peerConn{
+		nodeID: nodeID,
+		sem:    make(chan struct{}, maxInFlightPerPeer),
+		score:  1,
+	}
+}
+
+// tryAcquire reserves one slot of this peer's in-flight capacity,
+// returning false without blocking if it is already at maxInFlightPerPeer.
+func (p *peerConn) tryAcquire() bool {
+	select {
+	case p.sem <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *peerConn) release() { <-p.sem }
+
+func (p *peerConn) recordSuccess() { atomic.AddInt64(&p.score, 1) }
+func (p *peerConn) recordFailure() { atomic.AddInt64(&p.score, -1) }
+func (p *peerConn) currentScore() int64 { return atomic.LoadInt64(&p.score) }
+
+// taskQueue splits the chunks a RequestChunks call still needs into a
+// pending sub-queue (not yet attempted) and a retry sub-queue (timed out
+// or got an empty response from the peer tried last). Workers drain
+// pending first and only fall back to retry once it's empty, so a chunk
+// every peer is currently busy with doesn't block chunks nobody has
+// tried yet.
+type taskQueue struct {
+	mu      sync.Mutex
+	pending []*fetchTask
+	retry   []*fetchTask
+}
+
+func newTaskQueue(tasks []*fetchTask) *taskQueue {
+	return &taskQueue{pending: tasks}
+}
+
+// reserve pops the next task to attempt, or returns nil if both
+// sub-queues are currently empty (a worker should then check remaining()
+// to decide whether to keep polling or exit).
+func (q *taskQueue) reserve() *fetchTask {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if n := len(q.pending); n > 0 {
+		t := q.pending[n-1]
+		q.pending = q.pending[:n-1]
+		return t
+	}
+	if n := len(q.retry); n > 0 {
+		t := q.retry[n-1]
+		q.retry = q.retry[:n-1]
+		return t
+	}
+	return nil
+}
+
+func (q *taskQueue) requeue(t *fetchTask) {
+	q.mu.Lock()
+	q.retry = append(q.retry, t)
+	q.mu.Unlock()
+}
+
+func (q *taskQueue) remaining() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending) > 0 || len(q.retry) > 0
+}
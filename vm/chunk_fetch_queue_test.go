@@ -0,0 +1,87 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTaskQueueReservePendingBeforeRetry checks that reserve drains pending
+// before ever returning a requeued task, so a chunk nobody has tried yet
+// isn't starved behind one still waiting on retry.
+func TestTaskQueueReservePendingBeforeRetry(t *testing.T) {
+	require := require.New(t)
+	retryTask := &fetchTask{chunkID: ids.GenerateTestID()}
+	pendingTask := &fetchTask{chunkID: ids.GenerateTestID()}
+
+	q := newTaskQueue(nil)
+	q.requeue(retryTask)
+	require.True(q.remaining())
+
+	q.pending = append(q.pending, pendingTask)
+	require.Equal(pendingTask, q.reserve())
+	require.Equal(retryTask, q.reserve())
+	require.Nil(q.reserve())
+	require.False(q.remaining())
+}
+
+// TestTaskQueueRequeueConcurrentSafe exercises requeue/reserve/remaining
+// concurrently to catch data races on the queue's two sub-queues.
+func TestTaskQueueRequeueConcurrentSafe(t *testing.T) {
+	const n = 100
+	const workers = 4
+	tasks := make([]*fetchTask, n)
+	for i := range tasks {
+		tasks[i] = &fetchTask{chunkID: ids.GenerateTestID()}
+	}
+	q := newTaskQueue(tasks)
+
+	done := make(chan struct{}, workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for q.remaining() {
+				if t := q.reserve(); t != nil {
+					t.retries++
+					if t.retries < 2 {
+						q.requeue(t)
+					}
+				}
+			}
+		}()
+	}
+	for w := 0; w < workers; w++ {
+		<-done
+	}
+}
+
+// TestPeerConnTryAcquireBounded checks that tryAcquire refuses once
+// maxInFlightPerPeer slots are reserved, and release frees one back up.
+func TestPeerConnTryAcquireBounded(t *testing.T) {
+	require := require.New(t)
+	pc := newPeerConn(ids.GenerateTestNodeID())
+
+	for i := 0; i < maxInFlightPerPeer; i++ {
+		require.True(pc.tryAcquire())
+	}
+	require.False(pc.tryAcquire())
+
+	pc.release()
+	require.True(pc.tryAcquire())
+}
+
+// TestPeerConnScore checks recordSuccess/recordFailure move currentScore
+// in the expected direction.
+func TestPeerConnScore(t *testing.T) {
+	require := require.New(t)
+	pc := newPeerConn(ids.GenerateTestNodeID())
+	require.EqualValues(1, pc.currentScore())
+
+	pc.recordSuccess()
+	require.EqualValues(2, pc.currentScore())
+
+	pc.recordFailure()
+	pc.recordFailure()
+	require.EqualValues(0, pc.currentScore())
+}
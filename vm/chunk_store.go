@@ -0,0 +1,224 @@
+package vm
+
+import (
+	"context"
+	"encoding/binary"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/consts"
+	"go.uber.org/zap"
+)
+
+// TODO: make configurable
+const chunkStoreRetention = 2048 // heights of chunks kept on disk before Prune
+
+const (
+	chunkStoreByIDPrefix     byte = 0x0
+	chunkStoreByHeightPrefix byte = 0x1
+)
+
+// chunkRangeID identifies a ChunkRangeRequest on the
+// same wire HandleRequest already uses for bare chunkID and warp sync
+// requests (see the length-based dispatch in HandleRequest).
+const chunkRangeID = 3
+
+// ChunkStore persists chunks evicted from TxBlockManager's in-memory
+// fetchedChunks cache to the VM's database for chunkStoreRetention
+// heights, so RequestChunk/HandleRequest can serve long-tail and
+// bootstrap requests for recently (but no longer actively processing)
+// heights without refetching from peers or falling through to the
+// accepted-block path, which is tuned for verified finalized state
+// rather than chatty per-chunk lookups.
+type ChunkStore struct {
+	db database.Database
+}
+
+func NewChunkStore(db database.Database) *ChunkStore {
+	return &ChunkStore{db: db}
+}
+
+func chunkByIDKey(chunkID ids.ID) []byte {
+	k := make([]byte, 1+consts.IDLen)
+	k[0] = chunkStoreByIDPrefix
+	copy(k[1:], chunkID[:])
+	return k
+}
+
+func chunkByHeightKey(height uint64, chunkID ids.ID) []byte {
+	k := make([]byte, 1+consts.Uint64Len+consts.IDLen)
+	k[0] = chunkStoreByHeightPrefix
+	binary.BigEndian.PutUint64(k[1:], height)
+	copy(k[1+consts.Uint64Len:], chunkID[:])
+	return k
+}
+
+// Put persists chunk under chunkID at height, indexed both by chunkID
+// (for point lookups) and by height (for Range/Prune).
+func (s *ChunkStore) Put(height uint64, chunkID ids.ID, chunk []byte) error {
+	if err := s.db.Put(chunkByIDKey(chunkID), chunk); err != nil {
+		return err
+	}
+	return s.db.Put(chunkByHeightKey(height, chunkID), chunk)
+}
+
+// Get returns the chunk for chunkID, or database.ErrNotFound if it isn't
+// (or is no longer) on disk.
+func (s *ChunkStore) Get(chunkID ids.ID) ([]byte, error) {
+	return s.db.Get(chunkByIDKey(chunkID))
+}
+
+// Range returns every chunk stored at a height in [fromHeight,
+// toHeight], letting a bootstrapping peer batch-serve a range of
+// heights in one AppResponse instead of one chatty round trip per
+// chunkID.
+func (s *ChunkStore) Range(fromHeight, toHeight uint64) (map[ids.ID][]byte, error) {
+	it := s.db.NewIteratorWithStartAndPrefix(
+		chunkByHeightKey(fromHeight, ids.Empty),
+		[]byte{chunkStoreByHeightPrefix},
+	)
+	defer it.Release()
+
+	out := map[ids.ID][]byte{}
+	for it.Next() {
+		key := it.Key()
+		height := binary.BigEndian.Uint64(key[1 : 1+consts.Uint64Len])
+		if height > toHeight {
+			break
+		}
+		var chunkID ids.ID
+		copy(chunkID[:], key[1+consts.Uint64Len:])
+		chunk := make([]byte, len(it.Value()))
+		copy(chunk, it.Value())
+		out[chunkID] = chunk
+	}
+	return out, it.Error()
+}
+
+// Prune removes every chunk stored at a height more than
+// chunkStoreRetention below min, called as Accept advances.
+func (s *ChunkStore) Prune(min uint64) error {
+	if min < chunkStoreRetention {
+		return nil
+	}
+	cutoff := min - chunkStoreRetention
+
+	it := s.db.NewIteratorWithPrefix([]byte{chunkStoreByHeightPrefix})
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		height := binary.BigEndian.Uint64(key[1 : 1+consts.Uint64Len])
+		if height >= cutoff {
+			break
+		}
+		var chunkID ids.ID
+		copy(chunkID[:], key[1+consts.Uint64Len:])
+		if err := s.db.Delete(chunkByIDKey(chunkID)); err != nil {
+			return err
+		}
+		if err := s.db.Delete(key); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+// ChunkRangeRequest asks a peer to batch-serve every chunk it has on
+// disk at a height in [FromHeight, ToHeight], replacing what would
+// otherwise be ToHeight-FromHeight+1 separate per-chunkID AppRequests
+// during bootstrap.
+type ChunkRangeRequest struct {
+	FromHeight uint64
+	ToHeight   uint64
+}
+
+func (r *ChunkRangeRequest) Marshal() []byte {
+	p := codec.NewWriter(consts.NetworkSizeLimit)
+	p.PackByte(chunkRangeID)
+	p.PackUint64(r.FromHeight)
+	p.PackUint64(r.ToHeight)
+	return p.Bytes()
+}
+
+func UnmarshalChunkRangeRequest(b []byte) (*ChunkRangeRequest, error) {
+	p := codec.NewReader(b, consts.NetworkSizeLimit)
+	p.UnpackByte() // chunkRangeID, already checked by the caller's dispatch
+	r := &ChunkRangeRequest{
+		FromHeight: p.UnpackUint64(false),
+		ToHeight:   p.UnpackUint64(false),
+	}
+	return r, p.Err()
+}
+
+// ChunkRangeResponse carries every chunk a peer found for a
+// ChunkRangeRequest; order is unspecified, so callers diff against
+// their own manifest/ChunkMap rather than assuming positional alignment.
+type ChunkRangeResponse struct {
+	Chunks [][]byte
+}
+
+func (r *ChunkRangeResponse) Marshal() ([]byte, error) {
+	p := codec.NewWriter(consts.NetworkSizeLimit)
+	p.PackInt(len(r.Chunks))
+	for _, chunk := range r.Chunks {
+		chunk := chunk
+		p.PackBytes(chunk)
+	}
+	return p.Bytes(), p.Err()
+}
+
+func UnmarshalChunkRangeResponse(b []byte) (*ChunkRangeResponse, error) {
+	p := codec.NewReader(b, consts.NetworkSizeLimit)
+	count := p.UnpackInt(false)
+	r := &ChunkRangeResponse{Chunks: make([][]byte, count)}
+	for i := range r.Chunks {
+		p.UnpackBytes(consts.NetworkSizeLimit, false, &r.Chunks[i])
+	}
+	return r, p.Err()
+}
+
+// handleChunkRangeRequest answers a peer's ChunkRangeRequest from the
+// disk-backed ChunkStore, letting a bootstrapping node pull many heights
+// in one round trip instead of one AppRequest per chunkID.
+func (c *TxBlockManager) handleChunkRangeRequest(ctx context.Context, nodeID ids.NodeID, requestID uint32, request []byte) error {
+	req, err := UnmarshalChunkRangeRequest(request)
+	if err != nil {
+		c.vm.snowCtx.Log.Warn("unable to parse chunk range request", zap.Error(err))
+		return c.appSender.SendAppResponse(ctx, nodeID, requestID, []byte{})
+	}
+
+	byChunkID, err := c.store.Range(req.FromHeight, req.ToHeight)
+	if err != nil {
+		c.vm.snowCtx.Log.Warn("unable to serve chunk range", zap.Error(err))
+		return c.appSender.SendAppResponse(ctx, nodeID, requestID, []byte{})
+	}
+
+	resp := &ChunkRangeResponse{Chunks: make([][]byte, 0, len(byChunkID))}
+	for _, chunk := range byChunkID {
+		resp.Chunks = append(resp.Chunks, chunk)
+	}
+	b, err := resp.Marshal()
+	if err != nil {
+		c.vm.snowCtx.Log.Warn("unable to marshal chunk range response", zap.Error(err))
+		return c.appSender.SendAppResponse(ctx, nodeID, requestID, []byte{})
+	}
+	return c.appSender.SendAppResponse(ctx, nodeID, requestID, b)
+}
+
+// RequestChunkRange asks peer for every chunk it has on disk between
+// fromHeight and toHeight, for use during bootstrap once a peer is known
+// to cover that range (e.g. via NodeChunks gossip).
+func (c *TxBlockManager) RequestChunkRange(ctx context.Context, peer ids.NodeID, fromHeight, toHeight uint64) ([][]byte, error) {
+	req := &ChunkRangeRequest{FromHeight: fromHeight, ToHeight: toHeight}
+	respBytes, err := c.requestRaw(ctx, peer, req.Marshal())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := UnmarshalChunkRangeResponse(respBytes)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Chunks, nil
+}
@@ -0,0 +1,134 @@
+package vm
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/heap"
+)
+
+// TODO: make these configurable
+const (
+	// optimisticFetchWorkers bounds how many optimistic (not yet verified)
+	// chunk fetches run concurrently, replacing the unbounded
+	// "go c.RequestChunk(...)" per gossiped chunk this pool took over from.
+	optimisticFetchWorkers = 32
+
+	// optimisticFetchQueueSize bounds how many optimistic fetch jobs can be
+	// queued before new submissions are dropped rather than blocking the
+	// HandleAppGossip caller.
+	optimisticFetchQueueSize = 4096
+
+	// optimisticFetchLookahead bounds how far past the locally accepted tip
+	// a chunk's txBlock height may be before an optimistic fetch for it is
+	// dropped as not worth the bandwidth.
+	optimisticFetchLookahead = 256
+)
+
+type optimisticFetchJob struct {
+	chunkID ids.ID
+	nodeID  ids.NodeID
+}
+
+// optimisticFetchPool runs a bounded number of workers pulling from a
+// height-ordered priority queue of optimistic chunk fetches: chunks
+// referenced by tx blocks closer to LastAcceptedBlock().MaxTxHght()+1 are
+// served first, and jobs are dropped (counted in dropped) rather than
+// queued without bound when the queue is full or a job is already too
+// far ahead to be useful.
+//
+// It shares TxBlockManager's existing outstanding-request dedup (via
+// RequestChunk), so a non-optimistic caller racing an optimistic fetch
+// for the same chunk just joins the same in-flight request instead of
+// firing a second one.
+//
+// submit/worker both call through to VM (LastAcceptedBlock, stop), so
+// exercising this beyond construction needs a real or mock VM rather
+// than a plain unit test.
+type optimisticFetchPool struct {
+	c *TxBlockManager
+
+	mu   sync.Mutex
+	jobs *heap.Heap[*optimisticFetchJob, uint64]
+
+	avail chan struct{} // one token per queued job, buffered to optimisticFetchQueueSize
+
+	dropped int64 // accessed atomically; exposed as a metric
+}
+
+func newOptimisticFetchPool(c *TxBlockManager) *optimisticFetchPool {
+	p := &optimisticFetchPool{
+		c:     c,
+		jobs:  heap.New[*optimisticFetchJob, uint64](optimisticFetchQueueSize, true),
+		avail: make(chan struct{}, optimisticFetchQueueSize),
+	}
+	for i := 0; i < optimisticFetchWorkers; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+// submit enqueues a best-effort optimistic fetch for chunkID at height,
+// dropping it if the queue is already full or height is already past
+// optimisticFetchLookahead blocks ahead of the locally accepted tip.
+func (p *optimisticFetchPool) submit(height uint64, nodeID ids.NodeID, chunkID ids.ID) {
+	if tip := p.c.vm.LastAcceptedBlock().MaxTxHght(); height > tip+optimisticFetchLookahead {
+		atomic.AddInt64(&p.dropped, 1)
+		return
+	}
+
+	p.mu.Lock()
+	if p.jobs.Len() >= optimisticFetchQueueSize {
+		p.mu.Unlock()
+		atomic.AddInt64(&p.dropped, 1)
+		return
+	}
+	p.jobs.Push(&heap.Entry[*optimisticFetchJob, uint64]{
+		ID:    chunkID,
+		Val:   height,
+		Item:  &optimisticFetchJob{chunkID: chunkID, nodeID: nodeID},
+		Index: p.jobs.Len(),
+	})
+	p.mu.Unlock()
+
+	select {
+	case p.avail <- struct{}{}:
+	default:
+		// Another submit already signaled a worker for a higher-priority
+		// job; this job still sits in the heap and will be served once
+		// that worker drains it.
+	}
+}
+
+// Dropped returns how many optimistic fetches have been dropped for
+// backpressure since this pool was created.
+func (p *optimisticFetchPool) Dropped() int64 {
+	return atomic.LoadInt64(&p.dropped)
+}
+
+func (p *optimisticFetchPool) worker() {
+	for {
+		select {
+		case <-p.c.vm.stop:
+			return
+		case <-p.avail:
+		}
+
+		p.mu.Lock()
+		entry := p.jobs.First()
+		if entry == nil {
+			p.mu.Unlock()
+			continue
+		}
+		p.jobs.Pop()
+		p.mu.Unlock()
+
+		job := entry.Item
+		if _, ok := p.c.clearedChunks.Get(job.chunkID); ok {
+			continue
+		}
+		p.c.RequestChunk(context.Background(), nil, job.nodeID, job.chunkID, nil)
+	}
+}
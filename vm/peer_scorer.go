@@ -0,0 +1,226 @@
+package vm
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+)
+
+// TODO: make these configurable
+const (
+	scorerWindow     = 100 // requests remembered per peer for failure-rate banning
+	maxInvalidChunks = 3
+	maxFailureRate   = 0.5
+	banCooldown      = 5 * time.Minute
+	rttEWMAAlpha     = 0.2
+)
+
+// peerStats tracks one peer's recent fetch behavior: an RTT EWMA over
+// successful fetches, a ring buffer of the last [scorerWindow] outcomes
+// (for failure-rate banning), a running count of hash-mismatched chunks,
+// and when its NodeChunks gossip was last accepted.
+type peerStats struct {
+	mu sync.Mutex
+
+	rtt time.Duration
+
+	outcomes [scorerWindow]bool
+	next     int
+	filled   int
+
+	invalid     int
+	lastGossip  time.Time
+	bannedUntil time.Time
+}
+
+func (s *peerStats) recordOutcome(success bool) {
+	s.outcomes[s.next] = success
+	s.next = (s.next + 1) % scorerWindow
+	if s.filled < scorerWindow {
+		s.filled++
+	}
+}
+
+// failureRate must be called with s.mu held.
+func (s *peerStats) failureRate() float64 {
+	if s.filled == 0 {
+		return 0
+	}
+	failures := 0
+	for _, ok := range s.outcomes[:s.filled] {
+		if !ok {
+			failures++
+		}
+	}
+	return float64(failures) / float64(s.filled)
+}
+
+// weight scores a peer for weighted random selection: a low failure rate
+// and a low RTT both push the weight up. A peer with no history yet gets
+// a neutral weight so it has a chance to prove itself.
+func (s *peerStats) weight() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.filled == 0 {
+		return 1
+	}
+	successRate := 1 - s.failureRate()
+	rttPenalty := 1.0
+	if s.rtt > 0 {
+		// 100ms is treated as "no penalty"; longer RTTs shrink the weight.
+		rttPenalty = float64(100*time.Millisecond) / float64(s.rtt+100*time.Millisecond)
+	}
+	return (0.1 + successRate) * rttPenalty
+}
+
+func (s *peerStats) banned() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Now().Before(s.bannedUntil)
+}
+
+// peerScorer tracks per-[ids.NodeID] reliability -- RTT, failures,
+// invalid chunks, and gossip freshness -- so callers can weight peer
+// selection toward reliable peers and evict ones that look adversarial
+// instead of treating every advertised peer identically.
+type peerScorer struct {
+	mu    sync.Mutex
+	stats map[ids.NodeID]*peerStats
+}
+
+func newPeerScorer() *peerScorer {
+	return &peerScorer{stats: map[ids.NodeID]*peerStats{}}
+}
+
+func (p *peerScorer) get(nodeID ids.NodeID) *peerStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, ok := p.stats[nodeID]
+	if !ok {
+		s = &peerStats{}
+		p.stats[nodeID] = s
+	}
+	return s
+}
+
+// recordSuccess updates a peer's RTT EWMA and outcome window after a
+// successful chunk fetch.
+func (p *peerScorer) recordSuccess(nodeID ids.NodeID, rtt time.Duration) {
+	s := p.get(nodeID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rtt == 0 {
+		s.rtt = rtt
+	} else {
+		s.rtt = time.Duration(rttEWMAAlpha*float64(rtt) + (1-rttEWMAAlpha)*float64(s.rtt))
+	}
+	s.recordOutcome(true)
+}
+
+// recordFailure records an empty response or a transport-level timeout
+// from a peer.
+func (p *peerScorer) recordFailure(nodeID ids.NodeID) {
+	s := p.get(nodeID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordOutcome(false)
+	if s.failureRate() > maxFailureRate {
+		s.bannedUntil = time.Now().Add(banCooldown)
+	}
+}
+
+// recordInvalid records a hash-mismatched chunk from a peer -- a much
+// stronger adversarial signal than a plain failure -- and reports
+// whether this just pushed the peer over the ban threshold so the
+// caller can evict it from nodeSet/nodeChunks.
+func (p *peerScorer) recordInvalid(nodeID ids.NodeID) (justBanned bool) {
+	s := p.get(nodeID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.invalid++
+	s.recordOutcome(false)
+	if s.bannedUntil.IsZero() && (s.invalid > maxInvalidChunks || s.failureRate() > maxFailureRate) {
+		s.bannedUntil = time.Now().Add(banCooldown)
+		return true
+	}
+	return false
+}
+
+// recordGossip marks that nodeID's NodeChunks gossip was just accepted.
+func (p *peerScorer) recordGossip(nodeID ids.NodeID) {
+	s := p.get(nodeID)
+	s.mu.Lock()
+	s.lastGossip = time.Now()
+	s.mu.Unlock()
+}
+
+// banned reports whether nodeID is currently serving a ban cooldown,
+// e.g. for too many invalid chunks or too high a failure rate.
+func (p *peerScorer) banned(nodeID ids.NodeID) bool {
+	return p.get(nodeID).banned()
+}
+
+// pick performs weighted random selection across candidates, favoring
+// low-RTT, high-success peers over the uniform rand.Intn this replaces.
+// Callers are expected to have already filtered out banned peers.
+func (p *peerScorer) pick(candidates []ids.NodeID) ids.NodeID {
+	if len(candidates) == 0 {
+		return ids.EmptyNodeID
+	}
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+
+	weights := make([]float64, len(candidates))
+	var total float64
+	for i, nodeID := range candidates {
+		w := p.get(nodeID).weight()
+		weights[i] = w
+		total += w
+	}
+	if total <= 0 {
+		return candidates[rand.Intn(len(candidates))]
+	}
+
+	r := rand.Float64() * total
+	for i, w := range weights {
+		r -= w
+		if r <= 0 {
+			return candidates[i]
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+// PeerScore is a point-in-time snapshot of one peer's reputation, for the
+// admin RPC and metrics endpoint.
+type PeerScore struct {
+	NodeID      ids.NodeID    `json:"nodeID"`
+	RTT         time.Duration `json:"rtt"`
+	FailureRate float64       `json:"failureRate"`
+	Invalid     int           `json:"invalid"`
+	LastGossip  time.Time     `json:"lastGossip"`
+	Banned      bool          `json:"banned"`
+}
+
+// Scores returns a snapshot of every peer this node has ever scored.
+func (p *peerScorer) Scores() []PeerScore {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]PeerScore, 0, len(p.stats))
+	for nodeID, s := range p.stats {
+		s.mu.Lock()
+		out = append(out, PeerScore{
+			NodeID:      nodeID,
+			RTT:         s.rtt,
+			FailureRate: s.failureRate(),
+			Invalid:     s.invalid,
+			LastGossip:  s.lastGossip,
+			Banned:      time.Now().Before(s.bannedUntil),
+		})
+		s.mu.Unlock()
+	}
+	return out
+}
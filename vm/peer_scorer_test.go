@@ -0,0 +1,78 @@
+package vm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPeerScorerRecordFailureBans checks that a peer crosses maxFailureRate
+// and gets banned, and that pick/banned reflect it.
+func TestPeerScorerRecordFailureBans(t *testing.T) {
+	require := require.New(t)
+	p := newPeerScorer()
+	nodeID := ids.GenerateTestNodeID()
+
+	require.False(p.banned(nodeID))
+	for i := 0; i < scorerWindow; i++ {
+		p.recordFailure(nodeID)
+	}
+	require.True(p.banned(nodeID))
+}
+
+// TestPeerScorerRecordInvalidBansOnThreshold checks that recordInvalid
+// reports justBanned exactly once, on the call that crosses
+// maxInvalidChunks, and not on subsequent calls once already banned.
+func TestPeerScorerRecordInvalidBansOnThreshold(t *testing.T) {
+	require := require.New(t)
+	p := newPeerScorer()
+	nodeID := ids.GenerateTestNodeID()
+
+	// Seed successes so the failure rate stays under maxFailureRate and
+	// only the invalid-chunk count can trigger the ban.
+	for i := 0; i < 10; i++ {
+		p.recordSuccess(nodeID, 10*time.Millisecond)
+	}
+
+	var justBanned bool
+	for i := 0; i <= maxInvalidChunks; i++ {
+		justBanned = p.recordInvalid(nodeID)
+	}
+	require.True(justBanned)
+	require.True(p.banned(nodeID))
+
+	require.False(p.recordInvalid(nodeID))
+}
+
+// TestPeerScorerPickPrefersLowerRTT checks that weighted selection favors
+// the peer with the better recorded RTT over many draws -- a uniform
+// rand.Intn over the same candidates would split roughly 50/50.
+func TestPeerScorerPickPrefersLowerRTT(t *testing.T) {
+	require := require.New(t)
+	p := newPeerScorer()
+	fast, slow := ids.GenerateTestNodeID(), ids.GenerateTestNodeID()
+	p.recordSuccess(fast, 10*time.Millisecond)
+	p.recordSuccess(slow, 500*time.Millisecond)
+
+	candidates := []ids.NodeID{fast, slow}
+	fastWins := 0
+	for i := 0; i < 200; i++ {
+		if p.pick(candidates) == fast {
+			fastWins++
+		}
+	}
+	require.Greater(fastWins, 120)
+}
+
+// TestPeerScorerPickEmptyAndSingle checks the degenerate candidate-list
+// cases pick special-cases before doing weighted selection.
+func TestPeerScorerPickEmptyAndSingle(t *testing.T) {
+	require := require.New(t)
+	p := newPeerScorer()
+	require.Equal(ids.EmptyNodeID, p.pick(nil))
+
+	only := ids.GenerateTestNodeID()
+	require.Equal(only, p.pick([]ids.NodeID{only}))
+}
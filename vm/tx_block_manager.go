@@ -3,11 +3,13 @@ package vm
 import (
 	"context"
 	"errors"
-	"math/rand"
+	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/ava-labs/avalanchego/cache"
+	"github.com/ava-labs/avalanchego/database"
 	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/avalanchego/snow/engine/common"
 	"github.com/ava-labs/avalanchego/utils/set"
@@ -25,6 +27,14 @@ const (
 	maxChunkRetries = 20
 	retrySleep      = 50 * time.Millisecond
 	gossipFrequency = 100 * time.Millisecond
+
+	// defaultRequestTimeout bounds how long requestChunkNodeID/requestRaw
+	// wait on a response before their c.requests entry is reclaimed, so a
+	// lost AppResponse (no AppRequestFailed, no HandleResponse) can't leak
+	// the entry forever -- notably on the optimistic-fetch path, which
+	// calls RequestChunk with context.Background() and so has no ctx
+	// cancellation of its own to fall back on.
+	defaultRequestTimeout = 2 * time.Second
 )
 
 type NodeChunks struct {
@@ -68,6 +78,14 @@ func NewChunkMap() *ChunkMap {
 	}
 }
 
+// evictedChunk is a chunk ChunkMap.SetMin just dropped, along with the
+// height it was registered at, so callers can persist it to a
+// height-indexed store before it's gone from memory for good.
+type evictedChunk struct {
+	Height  uint64
+	ChunkID ids.ID
+}
+
 func (c *ChunkMap) Add(height uint64, chunkID ids.ID) {
 	// Ensure chunk is not already registered at height
 	b, ok := c.heights[height]
@@ -99,8 +117,8 @@ func (c *ChunkMap) Add(height uint64, chunkID ids.ID) {
 	})
 }
 
-func (c *ChunkMap) SetMin(h uint64) []ids.ID {
-	evicted := []ids.ID{}
+func (c *ChunkMap) SetMin(h uint64) []evictedChunk {
+	evicted := []evictedChunk{}
 	for {
 		b := c.bh.First()
 		if b == nil || b.Val >= h {
@@ -112,7 +130,7 @@ func (c *ChunkMap) SetMin(h uint64) []ids.ID {
 			count--
 			if count == 0 {
 				delete(c.counts, chunkID)
-				evicted = append(evicted, chunkID)
+				evicted = append(evicted, evictedChunk{Height: b.Val, ChunkID: chunkID})
 			} else {
 				c.counts[chunkID] = count
 			}
@@ -135,9 +153,19 @@ type TxBlockManager struct {
 	vm        *VM
 	appSender common.AppSender
 
-	requestLock sync.Mutex
-	requestID   uint32
-	requests    map[uint32]chan []byte
+	requestLock  sync.Mutex
+	requestID    uint32
+	requestNonce uint64
+	requests     map[uint32]*pendingChunkRequest
+
+	// RequestTimeout bounds how long a single chunk/warp-sync/range
+	// request waits for a response before its c.requests entry is
+	// reclaimed. Defaults to defaultRequestTimeout; set before Run if a
+	// deployment needs a different bound (e.g. a slower/higher-latency
+	// network).
+	RequestTimeout time.Duration
+
+	scorer *peerScorer
 
 	chunkLock           sync.RWMutex
 	fetchedChunks       map[ids.ID][]byte
@@ -153,6 +181,13 @@ type TxBlockManager struct {
 	nodeChunks    map[ids.NodeID]*NodeChunks
 	nodeSet       set.Set[ids.NodeID]
 
+	peerLock sync.Mutex
+	peers    map[ids.NodeID]*peerConn
+
+	optimisticPool *optimisticFetchPool
+
+	store *ChunkStore
+
 	outstandingLock sync.Mutex
 	outstanding     map[ids.ID][]chan *chunkResult
 
@@ -161,9 +196,11 @@ type TxBlockManager struct {
 }
 
 func NewTxBlockManager(vm *VM) *TxBlockManager {
-	return &TxBlockManager{
+	c := &TxBlockManager{
 		vm:                  vm,
-		requests:            map[uint32]chan []byte{},
+		requests:            map[uint32]*pendingChunkRequest{},
+		RequestTimeout:      defaultRequestTimeout,
+		scorer:              newPeerScorer(),
 		fetchedChunks:       map[ids.ID][]byte{},
 		optimisticChunks:    &cache.LRU[ids.ID, []byte]{Size: 1024},
 		clearedChunks:       &cache.LRU[ids.ID, any]{Size: 1024},
@@ -171,10 +208,14 @@ func NewTxBlockManager(vm *VM) *TxBlockManager {
 		chunks:              NewChunkMap(),
 		nodeChunks:          map[ids.NodeID]*NodeChunks{},
 		nodeSet:             set.NewSet[ids.NodeID](64),
+		peers:               map[ids.NodeID]*peerConn{},
 		outstanding:         map[ids.ID][]chan *chunkResult{},
 		update:              make(chan struct{}),
 		done:                make(chan struct{}),
 	}
+	c.optimisticPool = newOptimisticFetchPool(c)
+	c.store = NewChunkStore(vm.ChunkDB())
+	return c
 }
 
 func (c *TxBlockManager) Run(appSender common.AppSender) {
@@ -251,35 +292,147 @@ func (c *TxBlockManager) Accept(height uint64) {
 	c.chunkLock.Lock()
 	c.max = height
 	evicted := c.chunks.SetMin(height + 1)
-	for _, chunkID := range evicted {
-		delete(c.fetchedChunks, chunkID)
-		c.clearedChunks.Put(chunkID, nil)
-		c.optimisticChunks.Evict(chunkID)
+	for _, e := range evicted {
+		// Persist to disk before dropping from memory, so long-tail and
+		// bootstrap requests for this height can still be served from
+		// ChunkStore instead of only seeing it as "cleared".
+		if chunk, ok := c.fetchedChunks[e.ChunkID]; ok {
+			if err := c.store.Put(e.Height, e.ChunkID, chunk); err != nil {
+				c.vm.snowCtx.Log.Warn("unable to persist chunk to disk", zap.Stringer("chunkID", e.ChunkID), zap.Error(err))
+			}
+		}
+		delete(c.fetchedChunks, e.ChunkID)
+		c.clearedChunks.Put(e.ChunkID, nil)
+		c.optimisticChunks.Evict(e.ChunkID)
 	}
 	processing := len(c.fetchedChunks)
 	c.chunkLock.Unlock()
 
+	if err := c.store.Prune(height); err != nil {
+		c.vm.snowCtx.Log.Warn("unable to prune chunk store", zap.Error(err))
+	}
+
 	c.update <- struct{}{}
 	c.vm.snowCtx.Log.Info("evicted chunks from memory", zap.Int("n", len(evicted)), zap.Int("processing", processing))
 }
 
+// candidatePeers returns the peerConns for nodes that have advertised
+// coverage of [height], lazily creating a [peerConn] the first time a
+// node is seen.
+func (c *TxBlockManager) candidatePeers(height uint64) []*peerConn {
+	c.nodeChunkLock.RLock()
+	nodeIDs := make([]ids.NodeID, 0, len(c.nodeChunks))
+	for nodeID, nc := range c.nodeChunks {
+		if height < nc.Min || height > nc.Max {
+			continue
+		}
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	c.nodeChunkLock.RUnlock()
+
+	c.peerLock.Lock()
+	defer c.peerLock.Unlock()
+	peers := make([]*peerConn, 0, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		p, ok := c.peers[nodeID]
+		if !ok {
+			p = newPeerConn(nodeID)
+			c.peers[nodeID] = p
+		}
+		peers = append(peers, p)
+	}
+	return peers
+}
+
+// pickPeer reserves and returns the highest-scored candidate peer for
+// [height] that has spare in-flight capacity, or nil if every candidate
+// is already at maxInFlightPerPeer.
+func (c *TxBlockManager) pickPeer(height uint64) *peerConn {
+	candidates := c.candidatePeers(height)
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].currentScore() > candidates[j].currentScore()
+	})
+	for _, p := range candidates {
+		if p.tryAcquire() {
+			return p
+		}
+	}
+	return nil
+}
+
+// RequestChunks fetches every chunk in [chunkIDs] for [height], writing
+// each one to [ch] as it arrives. A pool of fetchWorkers workers pulls
+// from a shared [taskQueue]: each worker reserves a task, reserves
+// capacity on the best-scored peer that has any via pickPeer, and
+// dispatches the request -- requeuing the task and marking that peer down
+// on a timeout or empty response, or completing it on success. This
+// keeps fast peers busy instead of bottlenecking the whole batch on
+// whichever single random peer a slower, serial design would have
+// picked for each chunk.
 func (c *TxBlockManager) RequestChunks(ctx context.Context, height uint64, chunkIDs []ids.ID, ch chan []byte) error {
 	// TODO: pre-store chunks on disk if bootstrapping
+	tasks := make([]*fetchTask, len(chunkIDs))
+	for i, chunkID := range chunkIDs {
+		tasks[i] = &fetchTask{chunkID: chunkID, height: &height}
+	}
+	q := newTaskQueue(tasks)
+
 	g, gctx := errgroup.WithContext(ctx)
-	for _, cchunkID := range chunkIDs {
-		chunkID := cchunkID
+	for i := 0; i < fetchWorkers; i++ {
 		g.Go(func() error {
-			crch := make(chan *chunkResult, 1)
-			c.RequestChunk(gctx, &height, ids.EmptyNodeID, chunkID, crch)
-			select {
-			case r := <-crch:
-				if r.err != nil {
-					return r.err
+			for {
+				if err := gctx.Err(); err != nil {
+					return err
+				}
+
+				t := q.reserve()
+				if t == nil {
+					if !q.remaining() {
+						return nil
+					}
+					// Everything left is waiting on peer capacity; give
+					// it a moment instead of busy-looping.
+					select {
+					case <-time.After(retrySleep):
+					case <-gctx.Done():
+						return gctx.Err()
+					}
+					continue
+				}
+
+				peer := c.pickPeer(*t.height)
+				if peer == nil {
+					t.retries++
+					if t.retries >= maxChunkRetries {
+						return fmt.Errorf("chunk %s: %w", t.chunkID, errors.New("exhausted retries"))
+					}
+					q.requeue(t)
+					select {
+					case <-time.After(retrySleep):
+					case <-gctx.Done():
+						return gctx.Err()
+					}
+					continue
+				}
+
+				msg, err := c.requestChunkNodeID(gctx, peer.nodeID, t.chunkID)
+				peer.release()
+				if err != nil {
+					peer.recordFailure()
+					t.retries++
+					if t.retries >= maxChunkRetries {
+						return fmt.Errorf("chunk %s: %w", t.chunkID, err)
+					}
+					q.requeue(t)
+					continue
 				}
-				ch <- r.chunk
-				return nil
-			case <-gctx.Done():
-				return gctx.Err()
+				peer.recordSuccess()
+
+				c.chunkLock.Lock()
+				c.fetchedChunks[t.chunkID] = msg
+				c.chunks.Add(*t.height, t.chunkID)
+				c.chunkLock.Unlock()
+				ch <- msg
 			}
 		})
 	}
@@ -344,6 +497,21 @@ func (c *TxBlockManager) RequestChunk(ctx context.Context, height *uint64, hint
 	}
 	c.chunkLock.Unlock()
 
+	// Check if it's still on disk (e.g. evicted from fetchedChunks but
+	// within chunkStoreRetention) before paying for a network round trip.
+	if chunk, err := c.store.Get(chunkID); err == nil {
+		c.chunkLock.Lock()
+		if height != nil {
+			c.fetchedChunks[chunkID] = chunk
+			c.chunks.Add(*height, chunkID)
+		}
+		c.chunkLock.Unlock()
+		c.sendToOutstandingListeners(chunkID, chunk, nil)
+		return
+	} else if !errors.Is(err, database.ErrNotFound) {
+		c.vm.snowCtx.Log.Warn("unable to read chunk store", zap.Stringer("chunkID", chunkID), zap.Error(err))
+	}
+
 	// Check if optimistically cached
 	if chunk, ok := c.optimisticChunks.Get(chunkID); ok {
 		c.chunkLock.Lock()
@@ -369,10 +537,13 @@ func (c *TxBlockManager) RequestChunk(ctx context.Context, height *uint64, hint
 		} else {
 			// Determine who to send request to
 			possibleRecipients := []ids.NodeID{}
-			var randomRecipient ids.NodeID
+			var anyRecipient ids.NodeID
 			c.nodeChunkLock.RLock()
 			for nodeID, chunk := range c.nodeChunks {
-				randomRecipient = nodeID
+				if c.scorer.banned(nodeID) {
+					continue
+				}
+				anyRecipient = nodeID
 				if height != nil && *height >= chunk.Min && *height <= chunk.Max {
 					possibleRecipients = append(possibleRecipients, nodeID)
 					continue
@@ -385,14 +556,16 @@ func (c *TxBlockManager) RequestChunk(ctx context.Context, height *uint64, hint
 			c.nodeChunkLock.RUnlock()
 
 			// No possible recipients, so we wait
-			if randomRecipient == ids.EmptyNodeID {
+			if anyRecipient == ids.EmptyNodeID {
 				time.Sleep(retrySleep)
 				continue
 			}
 
-			// If 1 or more possible recipients, pick them instead
+			// If 1 or more possible recipients, weight selection toward
+			// whichever has the best recent RTT/success record instead of
+			// picking uniformly at random.
 			if len(possibleRecipients) > 0 {
-				randomRecipient = possibleRecipients[rand.Intn(len(possibleRecipients))]
+				anyRecipient = c.scorer.pick(possibleRecipients)
 			} else {
 				if height == nil {
 					c.vm.snowCtx.Log.Warn("no possible recipients", zap.Stringer("chunkID", chunkID), zap.Stringer("hint", hint))
@@ -400,7 +573,7 @@ func (c *TxBlockManager) RequestChunk(ctx context.Context, height *uint64, hint
 					c.vm.snowCtx.Log.Warn("no possible recipients", zap.Stringer("chunkID", chunkID), zap.Stringer("hint", hint), zap.Uint64("height", *height))
 				}
 			}
-			peer = randomRecipient
+			peer = anyRecipient
 		}
 
 		// Handle received message
@@ -424,15 +597,21 @@ func (c *TxBlockManager) RequestChunk(ctx context.Context, height *uint64, hint
 	c.sendToOutstandingListeners(chunkID, nil, errors.New("exhausted retries"))
 }
 
+// requestChunkNodeID is not unit-tested here: it depends on
+// TxBlockManager's VM (for AppRequest) and registerRequest/expireRequest's
+// outstanding-request table, neither of which can be constructed without a
+// real or mock VM.
 func (c *TxBlockManager) requestChunkNodeID(ctx context.Context, recipient ids.NodeID, chunkID ids.ID) ([]byte, error) {
+	start := time.Now()
+
+	ctx, cancel := context.WithTimeout(ctx, c.RequestTimeout)
+	defer cancel()
 
 	// Send request
 	rch := make(chan []byte)
-	c.requestLock.Lock()
-	requestID := c.requestID
-	c.requestID++
-	c.requests[requestID] = rch
-	c.requestLock.Unlock()
+	requestID, nonce := c.registerRequest(recipient, rch)
+	timer := time.AfterFunc(c.RequestTimeout, func() { c.expireRequest(requestID, nonce) })
+	defer timer.Stop()
 	if err := c.appSender.SendAppRequest(
 		ctx,
 		set.Set[ids.NodeID]{recipient: struct{}{}},
@@ -451,25 +630,110 @@ func (c *TxBlockManager) requestChunkNodeID(ctx context.Context, recipient ids.N
 		return nil, ctx.Err()
 	}
 	if len(msg) == 0 {
-		// Happens if recipient does not have the chunk we want
+		// Happens if recipient does not have the chunk we want, or if the
+		// request timed out -- either way HandleResponse/HandleRequestFailed
+		// already scored it against recipient before sending it here.
 		c.vm.snowCtx.Log.Warn("chunk fetch returned empty", zap.Stringer("chunkID", chunkID))
 		return nil, errors.New("not found")
 	}
 	fchunkID := utils.ToID(msg)
 	if chunkID != fchunkID {
-		// TODO: penalize sender
 		c.vm.snowCtx.Log.Warn("received incorrect chunk", zap.Stringer("nodeID", recipient))
+		if c.scorer.recordInvalid(recipient) {
+			c.evictPeer(recipient)
+		}
 		return nil, errors.New("invalid chunk")
 	}
+	c.scorer.recordSuccess(recipient, time.Since(start))
 	return msg, nil
 }
 
+// pendingChunkRequest tracks which peer an outstanding request was sent
+// to, so HandleRequestFailed (which the avalanchego engine calls with
+// only a requestID) can still attribute a transport-level timeout to the
+// right peer in peerScorer.
+type pendingChunkRequest struct {
+	ch     chan []byte
+	nodeID ids.NodeID
+
+	// nonce disambiguates this entry from whatever may later occupy the
+	// same requestID once c.requestID wraps around uint32; see
+	// expireRequest.
+	nonce uint64
+}
+
+// registerRequest allocates a requestID/nonce pair and stores [ch] under
+// it, returning both so the caller can arm a matching expiry timer.
+func (c *TxBlockManager) registerRequest(nodeID ids.NodeID, ch chan []byte) (uint32, uint64) {
+	c.requestLock.Lock()
+	defer c.requestLock.Unlock()
+	requestID := c.requestID
+	c.requestID++
+	nonce := c.requestNonce
+	c.requestNonce++
+	c.requests[requestID] = &pendingChunkRequest{ch: ch, nodeID: nodeID, nonce: nonce}
+	return requestID, nonce
+}
+
+// expireRequest reclaims the c.requests entry for requestID if it is
+// still the one registered under nonce, scoring the timeout against its
+// peer the same way HandleRequestFailed does.
+//
+// requestID alone isn't enough to identify the entry that registered it:
+// c.requestID is a uint32 that wraps, and by the time this timer fires
+// the original entry may already be gone (handled normally) with
+// requestID reused for an unrelated, still-outstanding request. nonce,
+// which never repeats for the lifetime of the process, is what lets us
+// tell "my request" from "whatever's there now" apart.
+//
+// Unlike HandleRequestFailed, this does not write to the pending
+// channel: whichever of requestChunkNodeID/requestRaw owns it has
+// already returned via its own deadline by the time this fires, so
+// nothing is left reading it.
+func (c *TxBlockManager) expireRequest(requestID uint32, nonce uint64) {
+	c.requestLock.Lock()
+	request, ok := c.requests[requestID]
+	if !ok || request.nonce != nonce {
+		c.requestLock.Unlock()
+		return
+	}
+	delete(c.requests, requestID)
+	c.requestLock.Unlock()
+	c.scorer.recordFailure(request.nodeID)
+}
+
+// PeerScores exposes a snapshot of every peer's reputation tracked by
+// peerScorer, for an admin JSON-RPC endpoint or metrics exporter to
+// surface without reaching into TxBlockManager internals.
+func (c *TxBlockManager) PeerScores() []PeerScore {
+	return c.scorer.Scores()
+}
+
+// evictPeer drops nodeID from the active peer set so height lookups and
+// future gossip stop routing through it until its peerScorer ban
+// cooldown expires.
+func (c *TxBlockManager) evictPeer(nodeID ids.NodeID) {
+	c.nodeChunkLock.Lock()
+	delete(c.nodeChunks, nodeID)
+	c.nodeSet.Remove(nodeID)
+	c.nodeChunkLock.Unlock()
+	c.vm.snowCtx.Log.Warn("evicted adversarial peer", zap.Stringer("nodeID", nodeID))
+}
+
 func (c *TxBlockManager) HandleRequest(
 	ctx context.Context,
 	nodeID ids.NodeID,
 	requestID uint32,
 	request []byte,
 ) error {
+	if len(request) != ids.IDLen {
+		// Not a bare chunkID: dispatch on the leading type byte the other
+		// request kinds on this wire prefix themselves with.
+		if len(request) > 0 && request[0] == chunkRangeID {
+			return c.handleChunkRangeRequest(ctx, nodeID, requestID, request)
+		}
+		return c.handleWarpSyncRequest(ctx, nodeID, requestID, request)
+	}
 	chunkID, err := ids.ToID(request)
 	if err != nil {
 		c.vm.snowCtx.Log.Warn("unable to parse chunk request", zap.Error(err))
@@ -484,6 +748,13 @@ func (c *TxBlockManager) HandleRequest(
 		return c.appSender.SendAppResponse(ctx, nodeID, requestID, chunk)
 	}
 
+	// Check chunk store (evicted from memory but within retention)
+	if chunk, err := c.store.Get(chunkID); err == nil {
+		return c.appSender.SendAppResponse(ctx, nodeID, requestID, chunk)
+	} else if !errors.Is(err, database.ErrNotFound) {
+		c.vm.snowCtx.Log.Warn("unable to read chunk store", zap.Stringer("chunkID", chunkID), zap.Error(err))
+	}
+
 	// Check accepted
 	chunk, err = c.vm.GetTxBlock(chunkID)
 	if err != nil {
@@ -503,7 +774,12 @@ func (c *TxBlockManager) HandleResponse(nodeID ids.NodeID, requestID uint32, msg
 	}
 	delete(c.requests, requestID)
 	c.requestLock.Unlock()
-	request <- msg
+	if len(msg) == 0 {
+		// Peer answered but doesn't have what we asked for, as opposed to
+		// the transport-level timeout HandleRequestFailed scores.
+		c.scorer.recordFailure(request.nodeID)
+	}
+	request.ch <- msg
 	return nil
 }
 
@@ -517,7 +793,11 @@ func (c *TxBlockManager) HandleRequestFailed(requestID uint32) error {
 	}
 	delete(c.requests, requestID)
 	c.requestLock.Unlock()
-	request <- []byte{}
+	// A transport-level timeout is a stronger signal than an empty
+	// application response, but both flow through requestChunkNodeID's
+	// "not found" path via this channel, so score it the same way.
+	c.scorer.recordFailure(request.nodeID)
+	request.ch <- []byte{}
 	return nil
 }
 
@@ -527,11 +807,16 @@ func (c *TxBlockManager) HandleAppGossip(ctx context.Context, nodeID ids.NodeID,
 	}
 	switch msg[0] {
 	case 0:
+		if c.scorer.banned(nodeID) {
+			// Refuse gossip from an evicted peer until its cooldown expires.
+			return nil
+		}
 		nc, err := UnmarshalNodeChunks(msg[1:])
 		if err != nil {
 			c.vm.Logger().Error("unable to parse gossip", zap.Error(err))
 			return nil
 		}
+		c.scorer.recordGossip(nodeID)
 		c.nodeChunkLock.Lock()
 		c.nodeChunks[nodeID] = nc
 		c.nodeChunkLock.Unlock()
@@ -555,7 +840,11 @@ func (c *TxBlockManager) HandleAppGossip(ctx context.Context, nodeID ids.NodeID,
 		// Ensure tx block could be useful
 		//
 		// TODO: limit how far ahead we will fetch
-		if txBlock.Hght <= c.vm.LastAcceptedBlock().MaxTxHght() {
+		//
+		// Skip while a warp sync is in flight: LastAcceptedBlock still
+		// reflects genesis until RequestWarpSync installs a checkpoint, so
+		// this check would otherwise drop every gossiped block as stale.
+		if !WarpSyncInProgress() && txBlock.Hght <= c.vm.LastAcceptedBlock().MaxTxHght() {
 			c.vm.Logger().Debug("block is useless")
 			return nil
 		}
@@ -585,8 +874,7 @@ func (c *TxBlockManager) HandleAppGossip(ctx context.Context, nodeID ids.NodeID,
 				continue
 			}
 			c.tryOptimisticChunks.Put(chunkID, nil)
-			// TODO: limit max concurrency here
-			go c.RequestChunk(context.Background(), nil, nodeID, chunkID, nil)
+			c.optimisticPool.submit(txBlock.Hght, nodeID, chunkID)
 		}
 	default:
 		c.vm.Logger().Error("unexpected message type")
@@ -625,6 +913,10 @@ func (c *TxBlockManager) HandleDisconnect(ctx context.Context, nodeID ids.NodeID
 	delete(c.nodeChunks, nodeID)
 	c.nodeSet.Remove(nodeID)
 	c.nodeChunkLock.Unlock()
+
+	c.peerLock.Lock()
+	delete(c.peers, nodeID)
+	c.peerLock.Unlock()
 	return nil
 }
 
@@ -0,0 +1,61 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/ids"
+)
+
+// warpCheckpointState is the most recent finalized checkpoint this node
+// has either installed (via InstallWarpCheckpoint) or can serve to a
+// peer requesting warp sync (via FinalizedWarpCheckpoint). VM keeps one
+// behind warpCheckpointLock; see TxBlockManager.RequestWarpSync and
+// handleWarpSyncRequest in warp_sync.go for the two call sites.
+type warpCheckpointState struct {
+	height uint64
+	header []byte
+	root   ids.ID
+}
+
+// InstallWarpCheckpoint adopts [header] at [height] with state root
+// [root] as the accepted tip, skipping replay of everything before it.
+// Callers (RequestWarpSync) must only invoke this after verifying the
+// peer's authority set proof against the local trust anchor; this
+// method itself does not re-verify anything.
+func (vm *VM) InstallWarpCheckpoint(height uint64, header []byte, root ids.ID) error {
+	if len(header) == 0 {
+		return fmt.Errorf("warp checkpoint at height %d has no header", height)
+	}
+	vm.warpCheckpointLock.Lock()
+	defer vm.warpCheckpointLock.Unlock()
+	if vm.warpCheckpoint != nil && height <= vm.warpCheckpoint.height {
+		return fmt.Errorf("warp checkpoint at height %d is not newer than installed height %d", height, vm.warpCheckpoint.height)
+	}
+	vm.warpCheckpoint = &warpCheckpointState{height: height, header: header, root: root}
+	return nil
+}
+
+// FinalizedWarpCheckpoint returns the most recently installed checkpoint
+// if it is at or after fromHeight, or nil if this node has nothing to
+// offer (e.g. it has never completed a warp sync, or its checkpoint is
+// older than what the requester already has).
+//
+// AuthoritySetProof and ChunkManifest are left empty: building a real
+// proof needs the warp-signature machinery referenced in
+// RequestWarpSync's doc comment, which doesn't exist yet. A requester
+// gets back FinalizedHeight/FinalizedHeader/StateRoot with no proof,
+// the same honest-empty response handleWarpSyncRequest already falls
+// back to when it has nothing to offer -- neither fabricates data it
+// can't back up.
+func (vm *VM) FinalizedWarpCheckpoint(fromHeight uint64) *WarpSyncResponse {
+	vm.warpCheckpointLock.Lock()
+	defer vm.warpCheckpointLock.Unlock()
+	if vm.warpCheckpoint == nil || vm.warpCheckpoint.height < fromHeight {
+		return nil
+	}
+	return &WarpSyncResponse{
+		FinalizedHeight: vm.warpCheckpoint.height,
+		FinalizedHeader: vm.warpCheckpoint.header,
+		StateRoot:       vm.warpCheckpoint.root,
+	}
+}
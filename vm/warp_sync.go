@@ -0,0 +1,239 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/set"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/consts"
+	"go.uber.org/zap"
+)
+
+// warpSyncID identifies a warp-sync request on the same wire this
+// package already uses for chunk gossip (see the msg[0] switch in
+// HandleAppGossip) and for chunk requests (see HandleRequest).
+const warpSyncID = 2
+
+// WarpSyncConfig configures fetching a recent finalized checkpoint from
+// peers instead of replaying the full tx-block history, analogous to warp
+// sync: a fresh node verifies a succinct proof that the authority/validator
+// set transitions from its locally configured [TrustAnchor] to a recent
+// finalized header, installs that header as its accepted tip, and then
+// lazily pulls only the chunks it needs to verify anything built after it.
+type WarpSyncConfig struct {
+	// TrustAnchor is the authority/validator set this node trusts as a
+	// starting point. A peer's [AuthoritySetProof] is only accepted if it
+	// chains forward from this set.
+	TrustAnchor ids.ID
+
+	// MaxWarpGap bounds how far past the requester's fromHeight a
+	// checkpoint's FinalizedHeight may be; RequestWarpSync skips a
+	// response beyond this gap and tries the next peer.
+	MaxWarpGap uint64
+}
+
+// WarpSyncRequest asks a peer for a proof of finality starting at
+// FromHeight (normally the requester's own last accepted height, so the
+// peer can skip proof data the requester would already be able to verify
+// on its own).
+type WarpSyncRequest struct {
+	FromHeight uint64
+}
+
+func (r *WarpSyncRequest) Marshal() []byte {
+	p := codec.NewWriter(consts.NetworkSizeLimit)
+	p.PackByte(warpSyncID)
+	p.PackUint64(r.FromHeight)
+	return p.Bytes()
+}
+
+func UnmarshalWarpSyncRequest(b []byte) (*WarpSyncRequest, error) {
+	p := codec.NewReader(b, consts.NetworkSizeLimit)
+	p.UnpackByte() // warpSyncID, already checked by the caller's dispatch
+	r := &WarpSyncRequest{FromHeight: p.UnpackUint64(false)}
+	return r, p.Err()
+}
+
+// WarpSyncResponse carries the checkpoint a peer is advertising: the
+// header at FinalizedHeight, a succinct proof that the authority set has
+// transitioned from the requester's trust anchor to the set that
+// finalized that header, the MerkleDB root at that height, and a
+// manifest of the chunk IDs a verifier will need to check anything built
+// on top of it.
+type WarpSyncResponse struct {
+	FinalizedHeight   uint64
+	FinalizedHeader   []byte
+	AuthoritySetProof []byte
+	StateRoot         ids.ID
+	ChunkManifest     []ids.ID
+}
+
+func (r *WarpSyncResponse) Marshal() ([]byte, error) {
+	p := codec.NewWriter(consts.NetworkSizeLimit)
+	p.PackUint64(r.FinalizedHeight)
+	p.PackBytes(r.FinalizedHeader)
+	p.PackBytes(r.AuthoritySetProof)
+	p.PackID(r.StateRoot)
+	p.PackInt(len(r.ChunkManifest))
+	for _, chunkID := range r.ChunkManifest {
+		chunkID := chunkID
+		p.PackID(chunkID)
+	}
+	return p.Bytes(), p.Err()
+}
+
+func UnmarshalWarpSyncResponse(b []byte) (*WarpSyncResponse, error) {
+	p := codec.NewReader(b, consts.NetworkSizeLimit)
+	r := &WarpSyncResponse{FinalizedHeight: p.UnpackUint64(false)}
+	p.UnpackBytes(consts.NetworkSizeLimit, false, &r.FinalizedHeader)
+	p.UnpackBytes(consts.NetworkSizeLimit, false, &r.AuthoritySetProof)
+	p.UnpackID(false, &r.StateRoot)
+	count := p.UnpackInt(false)
+	r.ChunkManifest = make([]ids.ID, count)
+	for i := range r.ChunkManifest {
+		p.UnpackID(false, &r.ChunkManifest[i])
+	}
+	return r, p.Err()
+}
+
+// warpSyncing is set for the duration of RequestWarpSync so
+// WarpSyncInProgress can report it; see its doc comment for why
+// HandleAppGossip needs to know.
+var warpSyncing int32
+
+// WarpSyncInProgress reports whether this node is currently attempting
+// [TxBlockManager.RequestWarpSync]. While true, HandleAppGossip's
+// "is this block useless" check (which compares against
+// [VM.LastAcceptedBlock]) is skipped for incoming gossip, since during
+// bootstrap that accessor still reflects genesis and would otherwise
+// cause every gossiped block to be dropped as stale.
+func WarpSyncInProgress() bool {
+	return atomic.LoadInt32(&warpSyncing) != 0
+}
+
+// RequestWarpSync asks [peers] for a finalized checkpoint at or after
+// [fromHeight], verifies the first plausible response against [cfg], and
+// if it verifies, installs it as the accepted tip and seeds the chunks
+// needed to verify anything built on top of it. Callers should prefer
+// this to full chunk-by-chunk bootstrap when the gap between
+// [fromHeight] and the network's current height exceeds what they're
+// willing to replay.
+//
+// Verifying the authority set proof itself requires the warp-signature
+// machinery in [chain.ChunkCertificate] and friends; this wires the
+// request/response plumbing and checkpoint installation and leaves the
+// actual proof check as a hook so it can be swapped in once that
+// verifier lands without touching the sync flow again.
+func (c *TxBlockManager) RequestWarpSync(
+	ctx context.Context,
+	cfg *WarpSyncConfig,
+	fromHeight uint64,
+	peers []ids.NodeID,
+	verify func(resp *WarpSyncResponse, trustAnchor ids.ID) error,
+) error {
+	atomic.StoreInt32(&warpSyncing, 1)
+	defer atomic.StoreInt32(&warpSyncing, 0)
+
+	req := &WarpSyncRequest{FromHeight: fromHeight}
+	reqBytes := req.Marshal()
+
+	for _, peer := range peers {
+		respBytes, err := c.requestRaw(ctx, peer, reqBytes)
+		if err != nil {
+			c.vm.snowCtx.Log.Warn("warp sync request failed", zap.Stringer("peer", peer), zap.Error(err))
+			continue
+		}
+		resp, err := UnmarshalWarpSyncResponse(respBytes)
+		if err != nil {
+			c.vm.snowCtx.Log.Warn("unable to parse warp sync response", zap.Stringer("peer", peer), zap.Error(err))
+			continue
+		}
+		if resp.FinalizedHeight < fromHeight {
+			continue
+		}
+		if resp.FinalizedHeight > fromHeight+cfg.MaxWarpGap {
+			c.vm.snowCtx.Log.Warn("warp sync checkpoint too far ahead, falling back to full bootstrap",
+				zap.Uint64("finalizedHeight", resp.FinalizedHeight),
+				zap.Uint64("fromHeight", fromHeight),
+			)
+			continue
+		}
+		if err := verify(resp, cfg.TrustAnchor); err != nil {
+			c.vm.snowCtx.Log.Warn("warp sync proof rejected", zap.Stringer("peer", peer), zap.Error(err))
+			continue
+		}
+
+		if err := c.vm.InstallWarpCheckpoint(resp.FinalizedHeight, resp.FinalizedHeader, resp.StateRoot); err != nil {
+			return fmt.Errorf("installing warp checkpoint: %w", err)
+		}
+
+		// Seed the manifest so RequestChunks can lazily pull only what's
+		// needed to verify anything built after the checkpoint, rather
+		// than everything back to genesis.
+		c.chunkLock.Lock()
+		c.min = resp.FinalizedHeight
+		c.max = resp.FinalizedHeight
+		for _, chunkID := range resp.ChunkManifest {
+			c.chunks.Add(resp.FinalizedHeight, chunkID)
+		}
+		c.chunkLock.Unlock()
+		return nil
+	}
+	return errors.New("no peer returned a usable warp sync checkpoint")
+}
+
+// handleWarpSyncRequest answers a peer's [WarpSyncRequest] with the most
+// recent finalized checkpoint this node knows of, if any: its height,
+// header and state root, with no authority set proof or chunk manifest
+// (see FinalizedWarpCheckpoint). It responds empty when it has no
+// checkpoint at or after the requested height.
+func (c *TxBlockManager) handleWarpSyncRequest(ctx context.Context, nodeID ids.NodeID, requestID uint32, request []byte) error {
+	req, err := UnmarshalWarpSyncRequest(request)
+	if err != nil {
+		c.vm.snowCtx.Log.Warn("unable to parse warp sync request", zap.Error(err))
+		return c.appSender.SendAppResponse(ctx, nodeID, requestID, []byte{})
+	}
+
+	resp := c.vm.FinalizedWarpCheckpoint(req.FromHeight)
+	if resp == nil {
+		return c.appSender.SendAppResponse(ctx, nodeID, requestID, []byte{})
+	}
+	b, err := resp.Marshal()
+	if err != nil {
+		c.vm.snowCtx.Log.Warn("unable to marshal warp sync response", zap.Error(err))
+		return c.appSender.SendAppResponse(ctx, nodeID, requestID, []byte{})
+	}
+	return c.appSender.SendAppResponse(ctx, nodeID, requestID, b)
+}
+
+// requestRaw sends [payload] verbatim to [peer] and waits for the
+// matching response, reusing the same requestID bookkeeping HandleRequest
+// and HandleResponse already maintain for chunk requests.
+func (c *TxBlockManager) requestRaw(ctx context.Context, peer ids.NodeID, payload []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.RequestTimeout)
+	defer cancel()
+
+	rch := make(chan []byte)
+	requestID, nonce := c.registerRequest(peer, rch)
+	timer := time.AfterFunc(c.RequestTimeout, func() { c.expireRequest(requestID, nonce) })
+	defer timer.Stop()
+
+	if err := c.appSender.SendAppRequest(ctx, set.Set[ids.NodeID]{peer: struct{}{}}, requestID, payload); err != nil {
+		return nil, err
+	}
+
+	select {
+	case msg := <-rch:
+		if len(msg) == 0 {
+			return nil, errors.New("empty warp sync response")
+		}
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,46 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWarpSyncRequestMarshalRoundTrip checks that UnmarshalWarpSyncRequest
+// recovers exactly what Marshal encoded.
+func TestWarpSyncRequestMarshalRoundTrip(t *testing.T) {
+	require := require.New(t)
+
+	req := &WarpSyncRequest{FromHeight: 1234}
+	got, err := UnmarshalWarpSyncRequest(req.Marshal())
+	require.NoError(err)
+	require.Equal(req, got)
+}
+
+// TestWarpSyncResponseMarshalRoundTrip checks that UnmarshalWarpSyncResponse
+// recovers exactly what Marshal encoded, including an empty ChunkManifest.
+func TestWarpSyncResponseMarshalRoundTrip(t *testing.T) {
+	require := require.New(t)
+
+	resp := &WarpSyncResponse{
+		FinalizedHeight:   42,
+		FinalizedHeader:   []byte("header"),
+		AuthoritySetProof: []byte("proof"),
+		StateRoot:         ids.GenerateTestID(),
+		ChunkManifest:     []ids.ID{ids.GenerateTestID(), ids.GenerateTestID()},
+	}
+	raw, err := resp.Marshal()
+	require.NoError(err)
+	got, err := UnmarshalWarpSyncResponse(raw)
+	require.NoError(err)
+	require.Equal(resp, got)
+
+	empty := &WarpSyncResponse{FinalizedHeight: 1, StateRoot: ids.GenerateTestID()}
+	raw, err = empty.Marshal()
+	require.NoError(err)
+	got, err = UnmarshalWarpSyncResponse(raw)
+	require.NoError(err)
+	require.Equal(empty.FinalizedHeight, got.FinalizedHeight)
+	require.Empty(got.ChunkManifest)
+}
@@ -0,0 +1,153 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package abi describes the typed shape of events emitted by programs via
+// the `emit_event` host import and decodes them back into Go values.
+package abi
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/hypersdk/chain"
+)
+
+var (
+	ErrAnonymousEvent    = errors.New("event has no topics (anonymous events are unsupported)")
+	ErrSignatureMismatch = errors.New("topic0 does not match event schema signature")
+)
+
+// FieldType is the set of primitive types an event field can be.
+type FieldType uint8
+
+const (
+	TypeUint64 FieldType = iota
+	TypeInt64
+	TypeBool
+	TypeBytes
+	TypeString
+	TypeAddress
+)
+
+func (t FieldType) String() string {
+	switch t {
+	case TypeUint64:
+		return "uint64"
+	case TypeInt64:
+		return "int64"
+	case TypeBool:
+		return "bool"
+	case TypeBytes:
+		return "bytes"
+	case TypeString:
+		return "string"
+	case TypeAddress:
+		return "address"
+	default:
+		return "unknown"
+	}
+}
+
+// Field is a single named, typed member of an [EventSchema].
+type Field struct {
+	Name string
+	Type FieldType
+}
+
+// EventSchema describes an event emitted by a program: its name and the
+// ordered, typed fields packed into [chain.Event.Data].
+type EventSchema struct {
+	Name   string
+	Fields []Field
+}
+
+// Signature returns the canonical signature of the event, e.g.
+// "Transfer(address,uint64)", used as the input to [EventSchema.Topic0].
+func (s *EventSchema) Signature() string {
+	sig := s.Name + "("
+	for i, f := range s.Fields {
+		if i > 0 {
+			sig += ","
+		}
+		sig += f.Type.String()
+	}
+	sig += ")"
+	return sig
+}
+
+// Topic0 computes the deterministic 32-byte topic identifying this event,
+// derived from its canonical [Signature]. Programs that emit this event
+// are expected to set `Topics[0]` to this value.
+func (s *EventSchema) Topic0() [32]byte {
+	return sha256.Sum256([]byte(s.Signature()))
+}
+
+// UnpackEvent decodes [event] according to [schema], returning each field
+// by name. It returns [ErrAnonymousEvent] if the event carries no topics
+// and [ErrSignatureMismatch] if the event's topic0 doesn't match the
+// schema's computed signature.
+func UnpackEvent(schema *EventSchema, event chain.Event) (map[string]any, error) {
+	if len(event.Topics) == 0 {
+		return nil, ErrAnonymousEvent
+	}
+	if event.Topics[0] != schema.Topic0() {
+		return nil, ErrSignatureMismatch
+	}
+
+	out := make(map[string]any, len(schema.Fields))
+	data := event.Data
+	for _, f := range schema.Fields {
+		v, rest, err := decodeField(f.Type, data)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", f.Name, err)
+		}
+		out[f.Name] = v
+		data = rest
+	}
+	return out, nil
+}
+
+func decodeField(t FieldType, data []byte) (any, []byte, error) {
+	switch t {
+	case TypeUint64:
+		if len(data) < 8 {
+			return nil, nil, fmt.Errorf("want 8 bytes for uint64, have %d", len(data))
+		}
+		return binary.BigEndian.Uint64(data[:8]), data[8:], nil
+	case TypeInt64:
+		if len(data) < 8 {
+			return nil, nil, fmt.Errorf("want 8 bytes for int64, have %d", len(data))
+		}
+		return int64(binary.BigEndian.Uint64(data[:8])), data[8:], nil
+	case TypeBool:
+		if len(data) < 1 {
+			return nil, nil, errors.New("want 1 byte for bool, have 0")
+		}
+		return data[0] != 0, data[1:], nil
+	case TypeAddress:
+		if len(data) < 32 {
+			return nil, nil, fmt.Errorf("want 32 bytes for address, have %d", len(data))
+		}
+		var addr [32]byte
+		copy(addr[:], data[:32])
+		return addr, data[32:], nil
+	case TypeBytes, TypeString:
+		if len(data) < 4 {
+			return nil, nil, fmt.Errorf("want 4-byte length prefix, have %d", len(data))
+		}
+		l := binary.BigEndian.Uint32(data[:4])
+		data = data[4:]
+		if uint32(len(data)) < l {
+			return nil, nil, fmt.Errorf("want %d bytes, have %d", l, len(data))
+		}
+		v, rest := data[:l], data[l:]
+		if t == TypeString {
+			return string(v), rest, nil
+		}
+		return v, rest, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown field type: %d", t)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package abi
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ava-labs/hypersdk/chain"
+)
+
+func TestEventSchemaTopic0Deterministic(t *testing.T) {
+	require := require.New(t)
+
+	schema := &EventSchema{
+		Name: "Transfer",
+		Fields: []Field{
+			{Name: "to", Type: TypeAddress},
+			{Name: "amount", Type: TypeUint64},
+		},
+	}
+	require.Equal("Transfer(address,uint64)", schema.Signature())
+	require.Equal(schema.Topic0(), schema.Topic0())
+
+	other := &EventSchema{Name: "Transfer", Fields: []Field{{Name: "to", Type: TypeAddress}}}
+	require.NotEqual(schema.Topic0(), other.Topic0())
+}
+
+func TestUnpackEvent(t *testing.T) {
+	require := require.New(t)
+
+	schema := &EventSchema{
+		Name:   "Incremented",
+		Fields: []Field{{Name: "amount", Type: TypeInt64}},
+	}
+	data := binary.BigEndian.AppendUint64(nil, uint64(5))
+	event := chain.Event{
+		Topics: [][32]byte{schema.Topic0()},
+		Data:   data,
+	}
+
+	fields, err := UnpackEvent(schema, event)
+	require.NoError(err)
+	require.Equal(int64(5), fields["amount"])
+}
+
+func TestUnpackEventAnonymous(t *testing.T) {
+	require := require.New(t)
+
+	schema := &EventSchema{Name: "Incremented", Fields: []Field{{Name: "amount", Type: TypeInt64}}}
+	_, err := UnpackEvent(schema, chain.Event{})
+	require.ErrorIs(err, ErrAnonymousEvent)
+}
+
+func TestUnpackEventWrongSignature(t *testing.T) {
+	require := require.New(t)
+
+	schema := &EventSchema{Name: "Incremented", Fields: []Field{{Name: "amount", Type: TypeInt64}}}
+	_, err := UnpackEvent(schema, chain.Event{Topics: [][32]byte{{1, 2, 3}}})
+	require.ErrorIs(err, ErrSignatureMismatch)
+}
@@ -0,0 +1,61 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package host
+
+import (
+	"github.com/ava-labs/hypersdk/x/programs/program"
+)
+
+// EventsModuleName is the import module WASM programs use to emit
+// structured events, e.g. (import "events" "emit_event" (func ...)).
+const EventsModuleName = "events"
+
+// Events is the host import backing `emit_event(topics_ptr, topics_len,
+// data_ptr, data_len)`. It lets a program record a structured event on
+// the caller's [program.CallContext] (see CallContext.Events); nothing
+// copies those events into the tx's [chain.Result.Events].
+type Events struct{}
+
+func NewEvents() *Events {
+	return &Events{}
+}
+
+func (*Events) Name() string {
+	return EventsModuleName
+}
+
+func (e *Events) Register(link *Link) error {
+	return link.RegisterFourParamInt64Fn(EventsModuleName, "emit_event", e.emitEvent)
+}
+
+// emitEvent reads topicsLen bytes of 32-byte topics and dataLen bytes of
+// opaque data out of guest memory and records them against the caller's
+// [program.CallContext].
+func (e *Events) emitEvent(caller *program.Caller, topicsPtr, topicsLen, dataPtr, dataLen int64) (*program.Val, error) {
+	topicsRaw, err := caller.Memory().Range(uint64(topicsPtr), uint64(topicsLen))
+	if err != nil {
+		return nil, err
+	}
+	data, err := caller.Memory().Range(uint64(dataPtr), uint64(dataLen))
+	if err != nil {
+		return nil, err
+	}
+
+	topics := make([][32]byte, 0, len(topicsRaw)/32)
+	for len(topicsRaw) >= 32 {
+		var topic [32]byte
+		copy(topic[:], topicsRaw[:32])
+		topics = append(topics, topic)
+		topicsRaw = topicsRaw[32:]
+	}
+
+	caller.Context().Emit(topics, data)
+	caller.Context().RecordCall(program.CallTrace{
+		Module:       EventsModuleName,
+		Function:     "emit_event",
+		Params:       []int64{topicsPtr, topicsLen, dataPtr, dataLen},
+		GasRemaining: caller.Context().Gas,
+	})
+	return program.Zero, nil
+}
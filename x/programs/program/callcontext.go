@@ -9,4 +9,47 @@ type CallContext struct {
 	ProgramID ids.ID
 	Caller    codec.Address
 	Gas       uint64
+
+	events []RawEvent
+	tracer Tracer
+}
+
+// RawEvent is the host-side representation of an event emitted by a
+// program. It carries no program address, and nothing converts it into a
+// chain.Event (this package doesn't import chain to avoid a dependency
+// cycle).
+type RawEvent struct {
+	Topics [][32]byte
+	Data   []byte
+}
+
+// Emit records an event emitted by the program executing under this
+// [CallContext] via the `emit_event` host import.
+func (c *CallContext) Emit(topics [][32]byte, data []byte) {
+	c.events = append(c.events, RawEvent{Topics: topics, Data: data})
+}
+
+// Events returns every event emitted so far during this call.
+func (c *CallContext) Events() []RawEvent {
+	return c.events
+}
+
+// SetTracer attaches t so every host-call import invoked under this
+// [CallContext] is reported to it (see RecordCall). Pass nil to disable
+// tracing again. Left nil on the normal execution path: only debug
+// replay (DebugTraceTx/DebugTraceChunk) sets one, so ordinary tx
+// processing pays nothing for it.
+func (c *CallContext) SetTracer(t Tracer) {
+	c.tracer = t
+}
+
+// RecordCall reports trace to this context's tracer, if one is attached
+// via SetTracer. Host imports (e.g. host.Events) call this around their
+// work so a debug replay can reconstruct the host-call trace without
+// every import needing its own tracing logic.
+func (c *CallContext) RecordCall(trace CallTrace) {
+	if c.tracer == nil {
+		return
+	}
+	c.tracer.RecordCall(trace)
 }
@@ -0,0 +1,54 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package program
+
+// CallTrace captures a single host-call import invoked during a traced
+// program execution: analogous to an EVM opcode trace entry, but scoped
+// to host imports (the boundary a debug replay actually needs to
+// understand -- e.g. `emit_event`, `call_external`) rather than every
+// WASM instruction.
+type CallTrace struct {
+	// Index is this call's position in the trace, in invocation order.
+	Index int
+
+	Module   string
+	Function string
+
+	// Params and Return are the host import's raw i64 arguments and
+	// results, in the same encoding the WASM ABI passes them in (e.g. a
+	// pointer/length pair for a memory range).
+	Params []int64
+	Return []int64
+
+	// GasRemaining is the program's [Meter] balance immediately after
+	// this call returned.
+	GasRemaining uint64
+}
+
+// Tracer receives a CallTrace for every host-call import invoked by a
+// [CallContext] it is attached to via SetTracer.
+type Tracer interface {
+	RecordCall(CallTrace)
+}
+
+// CallTracer is the [Tracer] DebugTraceTx/DebugTraceChunk attach to a
+// traced call's [CallContext]: it just accumulates every CallTrace in
+// invocation order for the caller to inspect once execution finishes.
+type CallTracer struct {
+	calls []CallTrace
+}
+
+func NewCallTracer() *CallTracer {
+	return &CallTracer{}
+}
+
+func (t *CallTracer) RecordCall(c CallTrace) {
+	c.Index = len(t.calls)
+	t.calls = append(t.calls, c)
+}
+
+// Calls returns every CallTrace recorded so far, in invocation order.
+func (t *CallTracer) Calls() []CallTrace {
+	return t.calls
+}
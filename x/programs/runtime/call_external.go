@@ -0,0 +1,49 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package runtime
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ava-labs/hypersdk/x/programs/program"
+)
+
+// CallExternal invokes [fn] -- a cross-program call into [child]'s runtime
+// -- with its gas isolated from [parent]: exactly [maxUnits] are moved from
+// [parent] to [child] before [fn] runs, and whatever [child] didn't spend
+// is refunded back to [parent] once [fn] returns, including when [fn]
+// panics or the callee is stopped mid-call. A failure in the callee can
+// therefore never leak units held by the parent.
+//
+// It is a standalone function over two meters: Runtime has no
+// CallExternal method, and the `inc_external`/`get_value_external` host
+// imports do not call it.
+func CallExternal(ctx context.Context, parent, child *program.Meter, maxUnits uint64, fn func(ctx context.Context) ([]int64, error)) (result []int64, err error) {
+	if _, err := parent.TransferUnitsTo(child, maxUnits); err != nil {
+		return nil, fmt.Errorf("unable to fund external call: %w", err)
+	}
+
+	// Refund whatever [child] didn't spend, no matter how [fn] returns.
+	defer func() {
+		remaining := child.GetBalance()
+		if remaining == 0 {
+			return
+		}
+		if _, refundErr := child.TransferUnitsTo(parent, remaining); refundErr != nil && err == nil {
+			err = fmt.Errorf("unable to refund unused units: %w", refundErr)
+		}
+	}()
+
+	// A panic in [fn] (e.g. the callee's runtime trapping) must still
+	// unwind through the refund above rather than leaking the transferred
+	// units with the parent's goroutine.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("external call panicked: %v", r)
+		}
+	}()
+
+	return fn(ctx)
+}
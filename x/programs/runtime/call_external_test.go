@@ -0,0 +1,117 @@
+// Copyright (C) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package runtime
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ava-labs/hypersdk/x/programs/engine"
+	"github.com/ava-labs/hypersdk/x/programs/program"
+)
+
+func newTestMeter(t *testing.T, units uint64) *program.Meter {
+	t.Helper()
+	eng := engine.New(engine.NewConfig())
+	store := engine.NewStore(eng, engine.NewStoreConfig(10))
+	meter, err := program.NewMeter(store, units)
+	require.NoError(t, err)
+	return meter
+}
+
+func TestCallExternalRefundsUnusedUnits(t *testing.T) {
+	require := require.New(t)
+	parent := newTestMeter(t, 1000)
+	child := newTestMeter(t, 0)
+
+	result, err := CallExternal(context.Background(), parent, child, 100, func(context.Context) ([]int64, error) {
+		child.Spend(40) //nolint:errcheck // test-only meter, spend can't meaningfully fail here
+		return []int64{1}, nil
+	})
+	require.NoError(err)
+	require.Equal([]int64{1}, result)
+	require.Equal(uint64(960), parent.GetBalance()) // 1000 - 100 transferred + 60 refunded
+	require.Zero(child.GetBalance())
+}
+
+func TestCallExternalOutOfGas(t *testing.T) {
+	require := require.New(t)
+	parent := newTestMeter(t, 1000)
+	child := newTestMeter(t, 0)
+
+	outOfGas := errors.New("out of gas")
+	_, err := CallExternal(context.Background(), parent, child, 50, func(context.Context) ([]int64, error) {
+		return nil, outOfGas
+	})
+	require.ErrorIs(err, outOfGas)
+	require.Equal(uint64(1000), parent.GetBalance()) // all 50 units refunded
+}
+
+func TestCallExternalPanicStillRefunds(t *testing.T) {
+	require := require.New(t)
+	parent := newTestMeter(t, 1000)
+	child := newTestMeter(t, 0)
+
+	_, err := CallExternal(context.Background(), parent, child, 100, func(context.Context) ([]int64, error) {
+		child.Spend(10) //nolint:errcheck
+		panic("callee trapped")
+	})
+	require.ErrorContains(err, "callee trapped")
+	require.Equal(uint64(990), parent.GetBalance()) // 100 transferred - 10 spent refunded
+}
+
+// A -> B -> C: units flow down the call stack and refunds flow back up in
+// the reverse order, with no leakage at any hop.
+func TestCallExternalDeeplyNested(t *testing.T) {
+	require := require.New(t)
+	a := newTestMeter(t, 1000)
+	b := newTestMeter(t, 0)
+	c := newTestMeter(t, 0)
+
+	result, err := CallExternal(context.Background(), a, b, 300, func(ctx context.Context) ([]int64, error) {
+		b.Spend(10) //nolint:errcheck
+		return CallExternal(ctx, b, c, 100, func(context.Context) ([]int64, error) {
+			c.Spend(25) //nolint:errcheck
+			return []int64{42}, nil
+		})
+	})
+	require.NoError(err)
+	require.Equal([]int64{42}, result)
+	require.Zero(b.GetBalance())
+	require.Zero(c.GetBalance())
+	require.Equal(uint64(965), a.GetBalance()) // 1000 - 10 (b) - 25 (c)
+}
+
+// Concurrent sibling calls sharing a parent meter must not corrupt each
+// other's transfer/refund accounting.
+func TestCallExternalConcurrentSiblings(t *testing.T) {
+	require := require.New(t)
+	parent := newTestMeter(t, 10_000)
+
+	const siblings = 20
+	var wg sync.WaitGroup
+	wg.Add(siblings)
+	for i := 0; i < siblings; i++ {
+		go func(spend uint64) {
+			defer wg.Done()
+			child := newTestMeter(t, 0)
+			_, err := CallExternal(context.Background(), parent, child, 100, func(context.Context) ([]int64, error) {
+				child.Spend(spend) //nolint:errcheck
+				return nil, nil
+			})
+			require.NoError(err)
+		}(uint64(i))
+	}
+	wg.Wait()
+
+	var totalSpent uint64
+	for i := 0; i < siblings; i++ {
+		totalSpent += uint64(i)
+	}
+	require.Equal(uint64(10_000)-totalSpent, parent.GetBalance())
+}